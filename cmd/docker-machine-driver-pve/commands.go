@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/rancher/machine/libmachine/log"
+	"github.com/stellatarum/docker-machine-driver-pve/cmd/docker-machine-driver-pve/driver"
+)
+
+// Command operating on machines created by the driver.
+type command struct {
+	// Arguments of the command shown in usage, excluding flags.
+	usage string
+
+	// Short description of the command.
+	description string
+
+	// Runs the command with arguments following the command name.
+	run func(ctx context.Context, args []string) error
+}
+
+// Available commands, keyed by name.
+var commands = map[string]command{
+	"snapshot": {
+		usage:       snapshotUsage,
+		description: "Manages snapshots of a machine",
+		run:         runSnapshotCommand,
+	},
+	"suspend": {
+		usage:       "--machine-dir <path> [--to-disk]",
+		description: "Suspends a machine to RAM or disk",
+		run:         runSuspendCommand,
+	},
+	"migrate": {
+		usage:       "--machine-dir <path> [--online] [--target-storage <name>] <node>",
+		description: "Migrates a machine to another node",
+		run:         runMigrateCommand,
+	},
+	"gc": {
+		usage:       gcUsage,
+		description: "Reports or removes machines in the resource pool missing in the machine store",
+		run:         runGCCommand,
+	},
+	"resume": {
+		usage:       "--machine-dir <path>",
+		description: "Resumes a machine suspended to RAM or disk",
+		run:         runResumeCommand,
+	},
+}
+
+// Runs a command with a given name, if it exists.
+// Returns whether the command was found.
+func runCommand(ctx context.Context, name string, args []string) bool {
+	command, found := commands[name]
+	if !found {
+		return false
+	}
+
+	if err := command.run(ctx, args); err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	return true
+}
+
+// Prints usage of all commands.
+func printCommandsUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	fmt.Fprint(os.Stderr, "Commands:\n\n")
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s %s %s\n    \t%s\n", os.Args[0], name, commands[name].usage, commands[name].description)
+	}
+}
+
+// Flags shared by commands operating on a single machine.
+type machineFlags struct {
+	machineDir string
+	debug      bool
+}
+
+// Returns a new set of flags for a command operating on a single machine.
+func newMachineFlagSet(name string) (*flag.FlagSet, *machineFlags) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	values := &machineFlags{}
+
+	flags.StringVar(&values.machineDir, "machine-dir", "", "Path to the machine's directory in the machine store (e.g. '~/.docker/machine/machines/<name>')")
+	flags.BoolVar(&values.debug, "debug", false, "Show debug logs")
+
+	return flags, values
+}
+
+// Loads the driver of the machine given by flags.
+func (f *machineFlags) loadDriver() (*driver.Driver, error) {
+	if strings.TrimSpace(f.machineDir) == "" {
+		return nil, errors.New("flag '--machine-dir' is required")
+	}
+
+	log.SetDebug(f.debug)
+
+	return driver.LoadDriver(f.machineDir)
+}
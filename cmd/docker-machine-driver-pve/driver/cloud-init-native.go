@@ -0,0 +1,138 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/rancher/machine/libmachine/log"
+)
+
+// Available modes of passing cloud-init configuration to the machine.
+const (
+	cloudinitModeISO    = "iso"
+	cloudinitModeNative = "native"
+)
+
+// Available modes of passing cloud-init configuration to the machine.
+var cloudinitModes = []string{
+	cloudinitModeISO,
+	cloudinitModeNative,
+}
+
+// Returns Bus/Device of the Proxmox VE cloud-init drive, or empty string if there is none.
+func getCloudinitDriveDeviceName(config *proxmox.VirtualMachineConfig) string {
+	if config == nil {
+		return ""
+	}
+
+	for deviceName, deviceConfig := range config.MergeDisks() {
+		volume, _, _ := strings.Cut(deviceConfig, ",")
+		if strings.HasSuffix(volume, "-cloudinit") || strings.HasSuffix(volume, "-cloudinit.qcow2") {
+			return deviceName
+		}
+	}
+
+	return ""
+}
+
+// Configures Proxmox VE cloud-init drive of the current machine.
+func (d *Driver) setupNativeCloudinit(ctx context.Context) error {
+	machine, err := d.getCurrentMachine(ctx)
+	if err != nil {
+		return err
+	}
+
+	sshPublicKey, err := os.ReadFile(d.GetSSHPublicKeyPath())
+	if err != nil {
+		return fmt.Errorf("failed to read machine's SSH public key: %w", err)
+	}
+
+	options, err := d.getNativeCloudinitOptions(string(sshPublicKey))
+	if err != nil {
+		return err
+	}
+
+	err = d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+		return vm.Config(ctx, options...)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to configure cloud-init for Proxmox VE virtual machine ID='%d': %w", machine.VMID, err)
+	}
+
+	// Drive is regenerated on start too, but only if the machine is started by Proxmox VE itself
+	if err := d.getPVEClient().Put(ctx, fmt.Sprintf("/nodes/%s/qemu/%d/cloudinit", machine.Node, machine.VMID), nil, nil); err != nil {
+		return fmt.Errorf("failed to regenerate cloud-init drive of Proxmox VE virtual machine ID='%d': %w", machine.VMID, err)
+	}
+
+	log.Debugf("Regenerated cloud-init drive '%s'", getCloudinitDriveDeviceName(machine.VirtualMachineConfig))
+
+	return nil
+}
+
+// Returns Proxmox VE cloud-init options for the current machine.
+func (d *Driver) getNativeCloudinitOptions(sshPublicKey string) ([]proxmox.VirtualMachineOption, error) {
+	networkInterfaceIndex, err := getNetworkInterfaceIndex(d.NetworkInterfaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	ipConfig := "ip=dhcp"
+	if d.IPAddress != "" {
+		ipConfig = fmt.Sprintf("ip=%s/%d", d.IPAddress, d.IPPool.Bits)
+
+		if d.IPGateway != "" {
+			ipConfig += ",gw=" + d.IPGateway
+		}
+	}
+
+	options := []proxmox.VirtualMachineOption{
+		{
+			Name:  "ciuser",
+			Value: d.SSHUser,
+		},
+		{
+			Name:  "sshkeys",
+			Value: encodePVESSHKeys(sshPublicKey),
+		},
+		{
+			Name:  fmt.Sprintf("ipconfig%d", networkInterfaceIndex),
+			Value: ipConfig,
+		},
+	}
+
+	if len(d.IPNameservers) > 0 {
+		options = append(options, proxmox.VirtualMachineOption{
+			Name:  "nameserver",
+			Value: strings.Join(d.IPNameservers, " "),
+		})
+	}
+
+	if len(d.IPSearchDomains) > 0 {
+		options = append(options, proxmox.VirtualMachineOption{
+			Name:  "searchdomain",
+			Value: strings.Join(d.IPSearchDomains, " "),
+		})
+	}
+
+	return options, nil
+}
+
+// Returns index of a network interface (e.g. 0 for 'net0').
+func getNetworkInterfaceIndex(networkInterfaceName string) (int, error) {
+	var index int
+
+	if _, err := fmt.Sscanf(networkInterfaceName, "net%d", &index); err != nil || fmt.Sprintf("net%d", index) != networkInterfaceName {
+		return -1, fmt.Errorf("network interface '%s' is not in format 'net<INDEX>'", networkInterfaceName)
+	}
+
+	return index, nil
+}
+
+// Encodes SSH public keys as expected by Proxmox VE, which decodes the value once more after the API request.
+func encodePVESSHKeys(sshPublicKeys string) string {
+	return strings.ReplaceAll(url.QueryEscape(strings.TrimSpace(sshPublicKeys)), "+", "%20")
+}
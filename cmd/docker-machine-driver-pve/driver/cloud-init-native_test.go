@@ -0,0 +1,76 @@
+package driver
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getCloudinitDriveDeviceName(t *testing.T) {
+	tests := map[string]*proxmox.VirtualMachineConfig{
+		"ide2": {
+			SCSI0: "local-lvm:base-9000-disk-0,size=8G",
+			IDE0:  "none,media=cdrom",
+			IDE2:  "local-lvm:vm-9000-cloudinit,media=cdrom",
+		},
+		"scsi1": {
+			SCSI0: "local:9000/base-9000-disk-0.qcow2,size=8G",
+			SCSI1: "local:9000/vm-9000-cloudinit.qcow2,media=cdrom",
+		},
+		"": {
+			SCSI0: "local-lvm:base-9000-disk-0,size=8G",
+			IDE2:  "none,media=cdrom",
+		},
+	}
+
+	for expectedDeviceName, config := range tests {
+		t.Run(expectedDeviceName, func(t *testing.T) {
+			require.Equal(t, expectedDeviceName, getCloudinitDriveDeviceName(config))
+		})
+	}
+}
+
+func Test_getNativeCloudinitOptions(t *testing.T) {
+	d := NewDriver("machine", "")
+	d.SSHUser = "service"
+	d.NetworkInterfaceName = "net1"
+
+	options, err := d.getNativeCloudinitOptions("ssh-ed25519 AAAA+b/c= user@host\n")
+	require.NoError(t, err)
+	require.Equal(t, []proxmox.VirtualMachineOption{
+		{Name: "ciuser", Value: "service"},
+		{Name: "sshkeys", Value: "ssh-ed25519%20AAAA%2Bb%2Fc%3D%20user%40host"},
+		{Name: "ipconfig1", Value: "ip=dhcp"},
+	}, options)
+
+	d.IPPool = &ipPool{Start: netip.MustParseAddr("10.20.0.10"), End: netip.MustParseAddr("10.20.0.50"), Bits: 24}
+	d.IPAddress = "10.20.0.10"
+	d.IPGateway = "10.20.0.1"
+	d.IPNameservers = []string{"10.20.0.2", "10.20.0.3"}
+	d.IPSearchDomains = []string{"example.com"}
+
+	options, err = d.getNativeCloudinitOptions("ssh-ed25519 AAAA")
+	require.NoError(t, err)
+	require.Equal(t, []proxmox.VirtualMachineOption{
+		{Name: "ciuser", Value: "service"},
+		{Name: "sshkeys", Value: "ssh-ed25519%20AAAA"},
+		{Name: "ipconfig1", Value: "ip=10.20.0.10/24,gw=10.20.0.1"},
+		{Name: "nameserver", Value: "10.20.0.2 10.20.0.3"},
+		{Name: "searchdomain", Value: "example.com"},
+	}, options)
+}
+
+func Test_getNetworkInterfaceIndex(t *testing.T) {
+	index, err := getNetworkInterfaceIndex("net12")
+	require.NoError(t, err)
+	require.Equal(t, 12, index)
+
+	for _, networkInterfaceName := range []string{"", "net", "eth0", "net1a", "net01"} {
+		t.Run(networkInterfaceName, func(t *testing.T) {
+			_, err := getNetworkInterfaceIndex(networkInterfaceName)
+			require.Error(t, err)
+		})
+	}
+}
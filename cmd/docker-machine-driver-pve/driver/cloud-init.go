@@ -14,22 +14,38 @@ import (
 
 // Configures cloud-init for the current machine.
 func (d *Driver) setupCloudinit(ctx context.Context) error {
+	if d.CloudinitMode == cloudinitModeNative {
+		return d.setupNativeCloudinit(ctx)
+	}
+
 	machine, err := d.getCurrentMachine(ctx)
 	if err != nil {
 		return err
 	}
 
-	cloudinitMetadata, err := d.generateCloudinitMetadata()
+	templateData := d.getCloudinitTemplateData(machine.Node)
+
+	cloudinitMetadata, err := d.generateCloudinitMetadata(templateData)
 	if err != nil {
 		return fmt.Errorf("failed to generate cloud-init metadata: %w", err)
 	}
 
-	cloudinitUserdata, err := d.generateCloudinitUserdata()
+	cloudinitUserdata, err := d.generateCloudinitUserdata(templateData)
 	if err != nil {
 		return fmt.Errorf("failed to generate cloud-init userdata: %w", err)
 	}
 
-	if err := machine.CloudInit(ctx, d.ISODeviceName, cloudinitUserdata, cloudinitMetadata, "", ""); err != nil {
+	cloudinitNetworkConfig, err := d.generateCloudinitNetworkConfig(getMACFromPveNetworkDevice(machine.VirtualMachineConfig.MergeNets()[d.NetworkInterfaceName]))
+	if err != nil {
+		return fmt.Errorf("failed to generate cloud-init network-config: %w", err)
+	}
+
+	// ISO left behind by an interrupted creation of the machine is replaced
+	if err := machine.UnmountCloudInitISO(ctx, d.ISODeviceName); err != nil {
+		return fmt.Errorf("failed to remove cloud-init ISO: %w", err)
+	}
+
+	if err := machine.CloudInit(ctx, d.ISODeviceName, cloudinitUserdata, cloudinitMetadata, "", cloudinitNetworkConfig); err != nil {
 		return fmt.Errorf("failed to configure cloud-init for Proxmox VE virtual machine ID='%d': %w", machine.VMID, err)
 	}
 
@@ -37,33 +53,52 @@ func (d *Driver) setupCloudinit(ctx context.Context) error {
 }
 
 // Blocks until cloud-init finishes setup on the current machine.
-func (d *Driver) waitForCloudinit() error {
-	ctx, cancel := context.WithTimeout(context.TODO(), pveTaskPollingTimeout)
+func (d *Driver) waitForCloudinit(ctx context.Context) error {
+	err := d.waitForCommandOnCurrentMachine(ctx, "sudo cloud-init status --wait", durationOrDefault(d.CloudinitTimeout, pveTaskPollingTimeout))
+	if errors.Is(err, ErrNonZeroExitCode) {
+		return fmt.Errorf("cloud-init finished with non-zero exit code: %w", err)
+	}
+
+	return err
+}
+
+// Runs a command on the current machine, retrying until the machine is reachable over SSH or a given timeout expires.
+func (d *Driver) waitForCommandOnCurrentMachine(ctx context.Context, command string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	for {
-		err := d.runCommandOnCurrentMachine("sudo cloud-init status --wait")
-		if err == nil {
-			return nil
+		err := d.runCommandOnCurrentMachine(ctx, command)
+		if err == nil || errors.Is(err, ErrNonZeroExitCode) {
+			return err
 		}
 
-		if errors.Is(err, ErrNonZeroExitCode) {
-			return fmt.Errorf("cloud-init finished with non-zero exit code: %w", err)
+		// Failure caused by the context is reported below instead of a retry
+		if ctx.Err() == nil {
+			log.Warnf("failed to execute '%s' over SSH, will retry: %s", command, err.Error())
 		}
 
-		log.Warn("failed to execute 'sudo cloud-init status --wait' over SSH, will retry:", err.Error())
-
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("timed out waiting for cloud-init to finish: %w", context.DeadlineExceeded)
-		case <-time.After(pveTaskPollingInterval):
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("timed out after %s waiting for '%s' to finish: %w", timeout, command, ctx.Err())
+			}
+
+			return fmt.Errorf("interrupted waiting for '%s' to finish: %w", command, ctx.Err())
+		case <-time.After(d.getPollingInterval()):
 			continue
 		}
 	}
 }
 
-// Removes cloud-init configuration from the current machine.
+// Removes cloud-init (or Ignition) configuration from the current machine.
 func (d *Driver) cleanupCloudinit(ctx context.Context) error {
+	// Proxmox VE cloud-init drive belongs to the machine and is regenerated on each start
+	if d.CloudinitMode == cloudinitModeNative {
+		log.Debug("Keeping Proxmox VE cloud-init drive")
+		return nil
+	}
+
 	machine, err := d.getCurrentMachine(ctx)
 	if err != nil {
 		return err
@@ -76,7 +111,7 @@ func (d *Driver) cleanupCloudinit(ctx context.Context) error {
 	err = d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
 		return vm.RemoveTag(ctx, proxmox.MakeTag(proxmox.TagCloudInit))
 	})
-	if err != nil {
+	if err != nil && !proxmox.IsErrNoop(err) {
 		return fmt.Errorf("failed to remove cloud-init tag: %w", err)
 	}
 
@@ -84,12 +119,29 @@ func (d *Driver) cleanupCloudinit(ctx context.Context) error {
 }
 
 // Generates cloud-init metadatadata for the current machine.
-func (d *Driver) generateCloudinitMetadata() (string, error) {
+func (d *Driver) generateCloudinitMetadata(templateData cloudinitTemplateData) (string, error) {
 	metadata := map[string]interface{}{
 		"instance-id": d.MachineName,
 		"hostname":    d.MachineName,
 	}
 
+	// Merge user-supplied metadata
+	if d.CloudinitMetadata != "" {
+		renderedMetadata, err := renderCloudinitTemplate("metadata", d.CloudinitMetadata, templateData)
+		if err != nil {
+			return "", err
+		}
+
+		userMetadata, err := parseCloudinitDocument(renderedMetadata)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse '--%s': %w", flagCloudinitMetadata, err)
+		}
+
+		if err := mergeCloudinitValues(metadata, userMetadata, ""); err != nil {
+			return "", fmt.Errorf("failed to merge '--%s': %w", flagCloudinitMetadata, err)
+		}
+	}
+
 	metadataYAML, err := yaml.Marshal(&metadata)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal cloud-init metadata: %w", err)
@@ -99,7 +151,7 @@ func (d *Driver) generateCloudinitMetadata() (string, error) {
 }
 
 // Generates cloud-init userdata for the current machine.
-func (d *Driver) generateCloudinitUserdata() (string, error) {
+func (d *Driver) generateCloudinitUserdata(templateData cloudinitTemplateData) (string, error) {
 	sshPublicKey, err := os.ReadFile(d.GetSSHPublicKeyPath())
 	if err != nil {
 		return "", fmt.Errorf("failed to read machine's SSH public key: %w", err)
@@ -121,10 +173,83 @@ func (d *Driver) generateCloudinitUserdata() (string, error) {
 		},
 	}
 
+	// Grow root partition and filesystem into the resized disk
+	if d.DiskSize != "" {
+		userdata["growpart"] = map[string]interface{}{
+			"mode":    "auto",
+			"devices": []string{"/"},
+		}
+		userdata["resize_rootfs"] = true
+	}
+
+	// Format and mount extra disks
+	diskSetup := map[string]interface{}{}
+	fsSetup := []map[string]interface{}{}
+	mounts := [][]string{}
+
+	for _, disk := range d.ExtraDisks {
+		if disk.MountPath == "" {
+			continue
+		}
+
+		diskSetup[disk.getGuestDevicePath()] = map[string]interface{}{
+			"table_type": "gpt",
+			"layout":     true,
+			"overwrite":  false,
+		}
+
+		fsSetup = append(fsSetup, map[string]interface{}{
+			"label":      disk.getSerial(),
+			"filesystem": disk.Filesystem,
+			"device":     disk.getGuestDevicePath(),
+			"partition":  "auto",
+		})
+
+		mounts = append(mounts, []string{"LABEL=" + disk.getSerial(), disk.MountPath, disk.Filesystem, "defaults,nofail", "0", "2"})
+	}
+
+	if len(mounts) > 0 {
+		userdata["disk_setup"] = diskSetup
+		userdata["fs_setup"] = fsSetup
+		userdata["mounts"] = mounts
+	}
+
 	userdataYAML, err := yaml.Marshal(&userdata)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal cloud-init userdata: %w", err)
 	}
 
-	return fmt.Sprintf("#cloud-config\n%s", userdataYAML), nil
+	// Merge user-supplied userdata, driver's userdata is parsed back so both use the same types
+	if d.CloudinitUserdata != "" {
+		mergedUserdata, err := parseCloudinitDocument(string(userdataYAML))
+		if err != nil {
+			return "", err
+		}
+
+		renderedUserdata, err := renderCloudinitTemplate("userdata", d.CloudinitUserdata, templateData)
+		if err != nil {
+			return "", err
+		}
+
+		userUserdata, err := parseCloudinitDocument(renderedUserdata)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse '--%s': %w", flagCloudinitUserdata, err)
+		}
+
+		if err := mergeCloudinitUserdata(mergedUserdata, userUserdata, d.SSHUser); err != nil {
+			return "", fmt.Errorf("failed to merge '--%s': %w", flagCloudinitUserdata, err)
+		}
+
+		if userdataYAML, err = yaml.Marshal(&mergedUserdata); err != nil {
+			return "", fmt.Errorf("failed to marshal cloud-init userdata: %w", err)
+		}
+	}
+
+	cloudinitUserdata := fmt.Sprintf("#cloud-config\n%s", userdataYAML)
+
+	if err := validateCloudinitUserdata(cloudinitUserdata); err != nil {
+		return "", fmt.Errorf("generated cloud-init userdata is invalid: %w", err)
+	}
+
+	return cloudinitUserdata, nil
 }
@@ -1,40 +1,91 @@
 package driver
 
 import (
+	"errors"
 	"fmt"
+	"net/netip"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rancher/machine/libmachine/drivers"
+	"github.com/rancher/machine/libmachine/log"
 	"github.com/rancher/machine/libmachine/mcnflag"
 )
 
 // Available flags.
 const (
-	flagURL              = "pve-url"
-	flagInsecureTLS      = "pve-insecure-tls"
-	flagTokenID          = "pve-token-id" //nolint:gosec // False-positive
-	flagTokenSecret      = "pve-token-secret"
-	flagResourcePool     = "pve-resource-pool"
-	flagTemplateID       = "pve-template"
-	flagISODevice        = "pve-iso-device"
-	flagNetworkInterface = "pve-network-interface"
-	flagSSHUser          = "pve-ssh-user"
-	flagSSHPort          = "pve-ssh-port"
-	flagProcessorSockets = "pve-processor-sockets"
-	flagProcessorCores   = "pve-processor-cores"
-	flagMemory           = "pve-memory"
-	flagMemoryBalloon    = "pve-memory-balloon"
-	flagFullClone        = "pve-full-clone"
+	flagURL                = "pve-url"
+	flagInsecureTLS        = "pve-insecure-tls"
+	flagTokenID            = "pve-token-id" //nolint:gosec // False-positive
+	flagTokenSecret        = "pve-token-secret"
+	flagResourcePool       = "pve-resource-pool"
+	flagTemplateID         = "pve-template"
+	flagISODevice          = "pve-iso-device"
+	flagNetworkInterface   = "pve-network-interface"
+	flagSSHUser            = "pve-ssh-user"
+	flagSSHPort            = "pve-ssh-port"
+	flagProcessorSockets   = "pve-processor-sockets"
+	flagProcessorCores     = "pve-processor-cores"
+	flagMemory             = "pve-memory"
+	flagMemoryBalloon      = "pve-memory-balloon"
+	flagFullClone          = "pve-full-clone"
+	flagTargetNode         = "pve-target-node"
+	flagPlacement          = "pve-placement"
+	flagPlacementNodes     = "pve-placement-nodes"
+	flagAntiAffinity       = "pve-anti-affinity-group"
+	flagAntiAffinityStrict = "pve-anti-affinity-strict"
+	flagStorage            = "pve-storage"
+	flagStorageFormat      = "pve-storage-format"
+	flagDiskSize           = "pve-disk-size"
+	flagDiskDevice         = "pve-disk-device"
+	flagExtraDisk          = "pve-extra-disk"
+	flagVMIDRange          = "pve-vmid-range"
+	flagIPPool             = "pve-ip-pool"
+	flagIPGateway          = "pve-ip-gateway"
+	flagIPNameservers      = "pve-ip-nameservers"
+	flagIPSearchDomains    = "pve-ip-search-domains"
+	flagCloudinitUserdata  = "pve-cloudinit-userdata"
+	flagCloudinitMetadata  = "pve-cloudinit-metadata"
+	flagTemplateVar        = "pve-template-var"
+	flagCloudinitMode      = "pve-cloudinit-mode"
+	flagProvisioner        = "pve-provisioner"
+	flagIgnitionConfig     = "pve-ignition-config"
+	flagShutdownTimeout    = "pve-shutdown-timeout"
+	flagShutdownForce      = "pve-shutdown-force"
+	flagProtection         = "pve-protection"
+	flagInitialSnapshot    = "pve-initial-snapshot"
+	flagCloneTimeout       = "pve-clone-timeout"
+	flagConfigTimeout      = "pve-config-timeout"
+	flagStartTimeout       = "pve-start-timeout"
+	flagCloudinitTimeout   = "pve-cloudinit-timeout"
+	flagDeleteTimeout      = "pve-delete-timeout"
+	flagPollingInterval    = "pve-polling-interval"
 )
 
+// Environment variable allowing removal of protected machines.
+const envForceRemove = "PVE_FORCE_REMOVE"
+
+// Available disk formats for full clones.
+var storageFormats = []string{
+	"raw",
+	"qcow2",
+}
+
 // Default values for flags.
 const (
 	defaultSSHUser = "service"
 	defaultSSHPort = 22
 )
 
+// Limits of Proxmox VE virtual machine IDs.
+const (
+	pveMinVMID = 100
+	pveMaxVMID = 999999999
+)
+
 // Driver's configuration.
 type config struct {
 	// Proxmox VE URL (e.g. 'https://<PROXMOX VE ADDRESS>:8006').
@@ -52,10 +103,22 @@ type config struct {
 	// Proxmox VE Resource Pool name.
 	ResourcePoolName string
 
-	// ID of the Proxmox VE template.
+	// ID of the Proxmox VE template, resolved from the template selector if not set directly.
 	TemplateID int
 
-	// Bus/Device of the CD/DVD Drive to mount cloud-init ISO to (e.g. 'scsi1').
+	// If set, name of the Proxmox VE template or its tag prefixed with 'tag:' (e.g. 'tag:ubuntu-24.04').
+	TemplateSelector string
+
+	// Provisioner of the machine's operating system (e.g. 'cloud-init', 'ignition').
+	Provisioner string
+
+	// If set, Ignition config (JSON) to merge with the config generated by the driver.
+	IgnitionConfig string
+
+	// Mode of passing cloud-init configuration to the machine (e.g. 'iso', 'native').
+	CloudinitMode string
+
+	// Bus/Device of the CD/DVD Drive to mount cloud-init ISO to (e.g. 'scsi1'), used only in 'iso' cloud-init mode.
 	ISODeviceName string
 
 	// Bus/Device of the network interface to read machine's IP address from (e.g. 'net0').
@@ -76,6 +139,93 @@ type config struct {
 
 	// Forces full copy of all disks, even if underlying storage supports linked clones.
 	FullClone bool
+
+	// If set, name of the Proxmox VE node to clone the machine onto.
+	TargetNodeName string
+
+	// If set, strategy for automatic selection of the node to clone the machine onto.
+	Placement string
+
+	// If set, names of the Proxmox VE nodes considered by automatic placement.
+	PlacementNodeNames []string
+
+	// If set, name of the anti-affinity group the machine belongs to.
+	AntiAffinityGroup string
+
+	// Fails placement instead of placing the machine on a node already running a machine from the same anti-affinity group.
+	StrictAntiAffinity bool
+
+	// If set, name of the Proxmox VE storage to place disks of a full clone onto.
+	StorageName string
+
+	// If set, format of disks of a full clone (e.g. 'raw', 'qcow2').
+	StorageFormat string
+
+	// If set, size to grow the root disk of the machine to (e.g. '64G').
+	DiskSize string
+
+	// If set, Bus/Device of the root disk to resize (e.g. 'scsi0'), defaults to the boot disk of the template.
+	DiskDeviceName string
+
+	// Additional data disks to attach to the machine.
+	ExtraDisks []extraDisk
+
+	// If set, lowest ID the machine may be created with.
+	VMIDRangeMin int
+
+	// If set, highest ID the machine may be created with.
+	VMIDRangeMax int
+
+	// If set, pool of static IPv4 addresses to allocate the machine's address from.
+	IPPool *ipPool
+
+	// If set, default gateway for the static IP address.
+	IPGateway string
+
+	// If set, nameservers for the static IP address.
+	IPNameservers []string
+
+	// If set, search domains for the static IP address.
+	IPSearchDomains []string
+
+	// Time to wait for the machine to shut down.
+	ShutdownTimeout time.Duration
+
+	// Forces the machine off if it does not shut down within the shutdown timeout.
+	ShutdownForce bool
+
+	// Protects the machine from removal.
+	Protection bool
+
+	// If set, name of the snapshot taken once the machine is initialized.
+	InitialSnapshot string
+
+	// Time to wait for the template to be cloned.
+	CloneTimeout time.Duration
+
+	// Time to wait for configuration tasks, and any other tasks without a dedicated timeout.
+	ConfigTimeout time.Duration
+
+	// Time to wait for the machine to start.
+	StartTimeout time.Duration
+
+	// Time to wait for cloud-init (or Ignition) to finish on the first boot.
+	CloudinitTimeout time.Duration
+
+	// Time to wait for the machine to be deleted.
+	DeleteTimeout time.Duration
+
+	// Interval of polling Proxmox VE tasks and the machine state.
+	PollingInterval time.Duration
+
+	// If set, template of cloud-init userdata to merge with the userdata generated by the driver.
+	CloudinitUserdata string
+
+	// If set, template of cloud-init metadata to merge with the metadata generated by the driver.
+	CloudinitMetadata string
+
+	// Variables available to cloud-init templates.
+	TemplateVars map[string]string
 }
 
 // GetCreateFlags implements drivers.Driver.
@@ -106,15 +256,35 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			EnvVar: flagEnvVarFromFlagName(flagResourcePool),
 			Usage:  "Proxmox VE Resource Pool name",
 		},
-		mcnflag.IntFlag{
+		mcnflag.StringFlag{
 			Name:   flagTemplateID,
 			EnvVar: flagEnvVarFromFlagName(flagTemplateID),
-			Usage:  "ID of the Proxmox VE template",
+			Usage:  "ID, name or tag (e.g. 'tag:ubuntu-24.04') of the Proxmox VE template; the newest template wins if several match",
+		},
+		mcnflag.StringFlag{
+			Name:   flagProvisioner,
+			EnvVar: flagEnvVarFromFlagName(flagProvisioner),
+			Usage:  fmt.Sprintf("Provisioner of the machine's operating system (one of '%s'), defaults to '%s'", strings.Join(provisioners, "', '"), provisionerCloudinit),
+		},
+		mcnflag.StringFlag{
+			Name:   flagIgnitionConfig,
+			EnvVar: flagEnvVarFromFlagName(flagIgnitionConfig),
+			Usage:  fmt.Sprintf("If set, path to a file or inline Ignition (JSON) config to merge with the config generated by the driver, requires '--%s=%s'", flagProvisioner, provisionerIgnition),
+		},
+		mcnflag.StringFlag{
+			Name:   flagCloudinitMode,
+			EnvVar: flagEnvVarFromFlagName(flagCloudinitMode),
+			Usage: fmt.Sprintf(
+				"Mode of passing cloud-init configuration to the machine: '%s' mounts generated ISO to a CD/DVD drive, '%s' uses cloud-init drive of the template, defaults to '%s'",
+				cloudinitModeISO,
+				cloudinitModeNative,
+				cloudinitModeISO,
+			),
 		},
 		mcnflag.StringFlag{
 			Name:   flagISODevice,
 			EnvVar: flagEnvVarFromFlagName(flagISODevice),
-			Usage:  "Bus/Device of the CD/DVD Drive to mount cloud-init ISO to (e.g. 'scsi1')",
+			Usage:  fmt.Sprintf("Bus/Device of the CD/DVD Drive to mount cloud-init ISO to (e.g. 'scsi1'), required in '%s' cloud-init mode", cloudinitModeISO),
 		},
 		mcnflag.StringFlag{
 			Name:   flagNetworkInterface,
@@ -156,6 +326,147 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			EnvVar: flagEnvVarFromFlagName(flagFullClone),
 			Usage:  "Forces full copy of all disks, even if underlying storage supports linked clones.",
 		},
+		mcnflag.StringFlag{
+			Name:   flagTargetNode,
+			EnvVar: flagEnvVarFromFlagName(flagTargetNode),
+			Usage:  "If set, name of the Proxmox VE node to clone the machine onto, defaults to the node of the template.",
+		},
+		mcnflag.StringFlag{
+			Name:   flagPlacement,
+			EnvVar: flagEnvVarFromFlagName(flagPlacement),
+			Usage:  fmt.Sprintf("If set, strategy for automatic selection of the node to clone the machine onto (one of '%s').", strings.Join(placementStrategies, "', '")),
+		},
+		mcnflag.StringFlag{
+			Name:   flagPlacementNodes,
+			EnvVar: flagEnvVarFromFlagName(flagPlacementNodes),
+			Usage:  "If set, comma-separated list of Proxmox VE node names considered by automatic placement, defaults to all nodes.",
+		},
+		mcnflag.StringFlag{
+			Name:   flagAntiAffinity,
+			EnvVar: flagEnvVarFromFlagName(flagAntiAffinity),
+			Usage:  "If set, name of the anti-affinity group; placement prefers nodes not running any other machine from the same group.",
+		},
+		mcnflag.BoolFlag{
+			Name:   flagAntiAffinityStrict,
+			EnvVar: flagEnvVarFromFlagName(flagAntiAffinityStrict),
+			Usage:  "Fails placement instead of placing the machine on a node already running a machine from the same anti-affinity group.",
+		},
+		mcnflag.StringFlag{
+			Name:   flagStorage,
+			EnvVar: flagEnvVarFromFlagName(flagStorage),
+			Usage:  fmt.Sprintf("If set, name of the Proxmox VE storage to place disks of a full clone onto, requires '--%s'.", flagFullClone),
+		},
+		mcnflag.StringFlag{
+			Name:   flagStorageFormat,
+			EnvVar: flagEnvVarFromFlagName(flagStorageFormat),
+			Usage:  fmt.Sprintf("If set, format of disks of a full clone (one of '%s'), requires '--%s'.", strings.Join(storageFormats, "', '"), flagStorage),
+		},
+		mcnflag.StringFlag{
+			Name:   flagDiskSize,
+			EnvVar: flagEnvVarFromFlagName(flagDiskSize),
+			Usage:  "If set, absolute size to grow the root disk of the machine to (e.g. '64G'); disks can not be shrunk.",
+		},
+		mcnflag.StringFlag{
+			Name:   flagDiskDevice,
+			EnvVar: flagEnvVarFromFlagName(flagDiskDevice),
+			Usage:  "If set, Bus/Device of the root disk to resize (e.g. 'scsi0'), defaults to the boot disk of the template.",
+		},
+		mcnflag.StringSliceFlag{
+			Name:   flagExtraDisk,
+			EnvVar: flagEnvVarFromFlagName(flagExtraDisk),
+			Usage: "Additional data disk to attach to the machine, can be repeated " +
+				"(e.g. 'storage=local-lvm,size=100G,bus=scsi,cache=none,discard=on,ssd=on,iothread=on,mount=/var/lib/longhorn,fs=ext4').",
+		},
+		mcnflag.StringFlag{
+			Name:   flagVMIDRange,
+			EnvVar: flagEnvVarFromFlagName(flagVMIDRange),
+			Usage:  "If set, range of IDs the machine may be created with (e.g. '5000-5999'), defaults to the next free ID.",
+		},
+		mcnflag.StringFlag{
+			Name:   flagIPPool,
+			EnvVar: flagEnvVarFromFlagName(flagIPPool),
+			Usage:  "If set, pool of static IPv4 addresses to allocate the machine's address from (e.g. '10.20.0.10-10.20.0.50/24'), defaults to DHCP.",
+		},
+		mcnflag.StringFlag{
+			Name:   flagIPGateway,
+			EnvVar: flagEnvVarFromFlagName(flagIPGateway),
+			Usage:  fmt.Sprintf("If set, default gateway for the static IP address, requires '--%s'.", flagIPPool),
+		},
+		mcnflag.StringFlag{
+			Name:   flagIPNameservers,
+			EnvVar: flagEnvVarFromFlagName(flagIPNameservers),
+			Usage:  fmt.Sprintf("If set, comma-separated list of nameservers for the static IP address, requires '--%s'.", flagIPPool),
+		},
+		mcnflag.StringFlag{
+			Name:   flagIPSearchDomains,
+			EnvVar: flagEnvVarFromFlagName(flagIPSearchDomains),
+			Usage:  fmt.Sprintf("If set, comma-separated list of search domains for the static IP address, requires '--%s'.", flagIPPool),
+		},
+		mcnflag.StringFlag{
+			Name:   flagShutdownTimeout,
+			EnvVar: flagEnvVarFromFlagName(flagShutdownTimeout),
+			Usage:  fmt.Sprintf("Time to wait for the machine to shut down (e.g. '90s', '2m'), defaults to '%s'.", pveTaskPollingTimeout),
+		},
+		mcnflag.BoolFlag{
+			Name:   flagShutdownForce,
+			EnvVar: flagEnvVarFromFlagName(flagShutdownForce),
+			Usage:  "Forces the machine off if it does not shut down within the shutdown timeout.",
+		},
+		mcnflag.BoolFlag{
+			Name:   flagProtection,
+			EnvVar: flagEnvVarFromFlagName(flagProtection),
+			Usage:  fmt.Sprintf("Protects the machine from removal, unless environment variable '%s=true' is set.", envForceRemove),
+		},
+		mcnflag.StringFlag{
+			Name:   flagCloneTimeout,
+			EnvVar: flagEnvVarFromFlagName(flagCloneTimeout),
+			Usage:  fmt.Sprintf("Time to wait for the template to be cloned (e.g. '30m'), defaults to '%s'.", pveTaskPollingTimeout),
+		},
+		mcnflag.StringFlag{
+			Name:   flagConfigTimeout,
+			EnvVar: flagEnvVarFromFlagName(flagConfigTimeout),
+			Usage:  fmt.Sprintf("Time to wait for configuration tasks and other tasks without a dedicated timeout, defaults to '%s'.", pveTaskPollingTimeout),
+		},
+		mcnflag.StringFlag{
+			Name:   flagStartTimeout,
+			EnvVar: flagEnvVarFromFlagName(flagStartTimeout),
+			Usage:  fmt.Sprintf("Time to wait for the machine to start, defaults to '%s'.", pveTaskPollingTimeout),
+		},
+		mcnflag.StringFlag{
+			Name:   flagCloudinitTimeout,
+			EnvVar: flagEnvVarFromFlagName(flagCloudinitTimeout),
+			Usage:  fmt.Sprintf("Time to wait for cloud-init (or Ignition) to finish on the first boot, defaults to '%s'.", pveTaskPollingTimeout),
+		},
+		mcnflag.StringFlag{
+			Name:   flagDeleteTimeout,
+			EnvVar: flagEnvVarFromFlagName(flagDeleteTimeout),
+			Usage:  fmt.Sprintf("Time to wait for the machine to be deleted, defaults to '%s'.", pveTaskPollingTimeout),
+		},
+		mcnflag.StringFlag{
+			Name:   flagPollingInterval,
+			EnvVar: flagEnvVarFromFlagName(flagPollingInterval),
+			Usage:  fmt.Sprintf("Interval of polling Proxmox VE tasks and the machine state (e.g. '1s'), defaults to '%s'.", pveTaskPollingInterval),
+		},
+		mcnflag.StringFlag{
+			Name:   flagInitialSnapshot,
+			EnvVar: flagEnvVarFromFlagName(flagInitialSnapshot),
+			Usage:  "If set, name of the snapshot taken once the machine is initialized, as a clean-state restore point.",
+		},
+		mcnflag.StringFlag{
+			Name:   flagCloudinitUserdata,
+			EnvVar: flagEnvVarFromFlagName(flagCloudinitUserdata),
+			Usage:  "If set, path to a file or inline YAML with cloud-init userdata to merge with the userdata generated by the driver, rendered as Go template.",
+		},
+		mcnflag.StringFlag{
+			Name:   flagCloudinitMetadata,
+			EnvVar: flagEnvVarFromFlagName(flagCloudinitMetadata),
+			Usage:  "If set, path to a file or inline YAML with cloud-init metadata to merge with the metadata generated by the driver, rendered as Go template.",
+		},
+		mcnflag.StringSliceFlag{
+			Name:   flagTemplateVar,
+			EnvVar: flagEnvVarFromFlagName(flagTemplateVar),
+			Usage:  "Variable available to cloud-init templates as '{{ .Vars.key }}', can be repeated (e.g. 'domain=example.com').",
+		},
 	}
 }
 
@@ -163,39 +474,47 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 //
 //nolint:cyclop,gocyclo
 func (d *Driver) SetConfigFromFlags(opts drivers.DriverOptions) error {
-	d.URL = opts.String(flagURL)
-	if d.URL == "" {
-		return fmt.Errorf("flag '--%s' is required", flagURL)
+	if err := d.setConnectionFromFlags(opts); err != nil {
+		return err
 	}
 
-	if _, err := url.Parse(d.URL); err != nil {
-		return fmt.Errorf("failed to parse Proxmox VE URL (flag '--%s'): %w", flagURL, err)
+	template := strings.TrimSpace(opts.String(flagTemplateID))
+	if template == "" {
+		return fmt.Errorf("flag '--%s' is required", flagTemplateID)
 	}
 
-	d.InsecureTLS = opts.Bool(flagInsecureTLS)
+	d.TemplateID, d.TemplateSelector = 0, ""
 
-	d.TokenID = opts.String(flagTokenID)
-	if d.TokenID == "" {
-		return fmt.Errorf("flag '--%s' is required", flagTokenID)
+	if templateID, err := strconv.Atoi(template); err == nil {
+		if templateID <= 0 {
+			return fmt.Errorf("flag '--%s' must be > 0 when set to an ID", flagTemplateID)
+		}
+
+		d.TemplateID = templateID
+	} else {
+		d.TemplateSelector = template
 	}
 
-	d.TokenSecret = opts.String(flagTokenSecret)
-	if d.TokenSecret == "" {
-		return fmt.Errorf("flag '--%s' is required", flagTokenSecret)
+	if d.TemplateSelector == templateSelectorTagPrefix {
+		return fmt.Errorf("flag '--%s' must include a tag after '%s'", flagTemplateID, templateSelectorTagPrefix)
 	}
 
-	d.ResourcePoolName = opts.String(flagResourcePool)
-	if d.ResourcePoolName == "" {
-		return fmt.Errorf("flag '--%s' is required", flagResourcePool)
+	d.Provisioner = strings.ToLower(strings.TrimSpace(opts.String(flagProvisioner)))
+	if d.Provisioner == "" {
+		d.Provisioner = provisionerCloudinit
+	} else if !slices.Contains(provisioners, d.Provisioner) {
+		return fmt.Errorf("flag '--%s' must be one of '%s'", flagProvisioner, strings.Join(provisioners, "', '"))
 	}
 
-	d.TemplateID = opts.Int(flagTemplateID)
-	if d.TemplateID <= 0 {
-		return fmt.Errorf("flag '--%s' is required and must be >= 0", flagTemplateID)
+	d.CloudinitMode = strings.ToLower(strings.TrimSpace(opts.String(flagCloudinitMode)))
+	if d.CloudinitMode == "" {
+		d.CloudinitMode = cloudinitModeISO
+	} else if !slices.Contains(cloudinitModes, d.CloudinitMode) {
+		return fmt.Errorf("flag '--%s' must be one of '%s'", flagCloudinitMode, strings.Join(cloudinitModes, "', '"))
 	}
 
 	d.ISODeviceName = strings.ToLower(opts.String(flagISODevice))
-	if d.ISODeviceName == "" {
+	if d.ISODeviceName == "" && d.CloudinitMode == cloudinitModeISO {
 		return fmt.Errorf("flag '--%s' is required", flagISODevice)
 	}
 
@@ -204,6 +523,12 @@ func (d *Driver) SetConfigFromFlags(opts drivers.DriverOptions) error {
 		return fmt.Errorf("flag '--%s' is required", flagNetworkInterface)
 	}
 
+	if d.Provisioner == provisionerCloudinit && d.CloudinitMode == cloudinitModeNative {
+		if _, err := getNetworkInterfaceIndex(d.NetworkInterfaceName); err != nil {
+			return fmt.Errorf("failed to parse '--%s': %w", flagNetworkInterface, err)
+		}
+	}
+
 	d.SSHUser = opts.String(flagSSHUser)
 	if d.SSHUser == "" {
 		d.SSHUser = defaultSSHUser
@@ -258,6 +583,252 @@ func (d *Driver) SetConfigFromFlags(opts drivers.DriverOptions) error {
 
 	d.FullClone = opts.Bool(flagFullClone)
 
+	d.TargetNodeName = strings.TrimSpace(opts.String(flagTargetNode))
+
+	d.Placement = strings.ToLower(strings.TrimSpace(opts.String(flagPlacement)))
+	if d.Placement != "" && !slices.Contains(placementStrategies, d.Placement) {
+		return fmt.Errorf("flag '--%s' must be one of '%s'", flagPlacement, strings.Join(placementStrategies, "', '"))
+	}
+
+	if d.Placement != "" && d.TargetNodeName != "" {
+		return fmt.Errorf("flags '--%s' and '--%s' are mutually exclusive", flagPlacement, flagTargetNode)
+	}
+
+	d.PlacementNodeNames = parseStringFlagToList(opts.String(flagPlacementNodes))
+	if len(d.PlacementNodeNames) > 0 && d.Placement == "" {
+		return fmt.Errorf("flag '--%s' requires flag '--%s' to be set", flagPlacementNodes, flagPlacement)
+	}
+
+	d.AntiAffinityGroup = strings.ToLower(strings.TrimSpace(opts.String(flagAntiAffinity)))
+	if d.AntiAffinityGroup != "" && !pveTagRegexp.MatchString(d.AntiAffinityGroup) {
+		return fmt.Errorf("flag '--%s' may only contain letters, digits and characters '-', '_', '+', '.'", flagAntiAffinity)
+	}
+
+	d.StrictAntiAffinity = opts.Bool(flagAntiAffinityStrict)
+	if d.StrictAntiAffinity && d.AntiAffinityGroup == "" {
+		return fmt.Errorf("flag '--%s' requires flag '--%s' to be set", flagAntiAffinityStrict, flagAntiAffinity)
+	}
+
+	// Proxmox VE can change storage and format only when copying the disks
+	d.StorageName = strings.TrimSpace(opts.String(flagStorage))
+	if d.StorageName != "" && !d.FullClone {
+		return fmt.Errorf("flag '--%s' requires flag '--%s' to be set, linked clones always use storage of the template", flagStorage, flagFullClone)
+	}
+
+	d.StorageFormat = strings.ToLower(strings.TrimSpace(opts.String(flagStorageFormat)))
+	if d.StorageFormat != "" && !slices.Contains(storageFormats, d.StorageFormat) {
+		return fmt.Errorf("flag '--%s' must be one of '%s'", flagStorageFormat, strings.Join(storageFormats, "', '"))
+	}
+
+	if d.StorageFormat != "" && d.StorageName == "" {
+		return fmt.Errorf("flag '--%s' requires flag '--%s' to be set", flagStorageFormat, flagStorage)
+	}
+
+	d.DiskSize = strings.ToUpper(strings.TrimSpace(opts.String(flagDiskSize)))
+	if d.DiskSize != "" {
+		if _, err := parseDiskSize(d.DiskSize); err != nil {
+			return fmt.Errorf("failed to parse '--%s': %w", flagDiskSize, err)
+		}
+	}
+
+	d.DiskDeviceName = strings.ToLower(strings.TrimSpace(opts.String(flagDiskDevice)))
+	if d.DiskDeviceName != "" && d.DiskSize == "" {
+		return fmt.Errorf("flag '--%s' requires flag '--%s' to be set", flagDiskDevice, flagDiskSize)
+	}
+
+	d.ExtraDisks = nil
+
+	for _, spec := range opts.StringSlice(flagExtraDisk) {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+
+		disk, err := parseExtraDisk(spec)
+		if err != nil {
+			return fmt.Errorf("failed to parse '--%s' value '%s': %w", flagExtraDisk, spec, err)
+		}
+
+		d.ExtraDisks = append(d.ExtraDisks, disk)
+	}
+
+	if d.VMIDRangeMin, d.VMIDRangeMax, err = parseVMIDRange(opts.String(flagVMIDRange)); err != nil {
+		return fmt.Errorf("failed to parse '--%s': %w", flagVMIDRange, err)
+	}
+
+	d.ShutdownForce = opts.Bool(flagShutdownForce)
+
+	if err := d.setTimeoutsFromFlags(opts); err != nil {
+		return err
+	}
+
+	d.Protection = opts.Bool(flagProtection)
+
+	d.InitialSnapshot = strings.TrimSpace(opts.String(flagInitialSnapshot))
+	if d.InitialSnapshot != "" {
+		if err := checkSnapshotName(d.InitialSnapshot); err != nil {
+			return fmt.Errorf("failed to parse '--%s': %w", flagInitialSnapshot, err)
+		}
+	}
+
+	if d.CloudinitUserdata, err = loadCloudinitTemplate(opts.String(flagCloudinitUserdata)); err != nil {
+		return fmt.Errorf("failed to parse '--%s': %w", flagCloudinitUserdata, err)
+	}
+
+	if d.CloudinitMetadata, err = loadCloudinitTemplate(opts.String(flagCloudinitMetadata)); err != nil {
+		return fmt.Errorf("failed to parse '--%s': %w", flagCloudinitMetadata, err)
+	}
+
+	d.TemplateVars = map[string]string{}
+
+	for _, value := range opts.StringSlice(flagTemplateVar) {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		key, varValue, err := parseTemplateVar(value)
+		if err != nil {
+			return fmt.Errorf("failed to parse '--%s' value '%s': %w", flagTemplateVar, value, err)
+		}
+
+		d.TemplateVars[key] = varValue
+	}
+
+	if err := d.setIPConfigFromFlags(opts); err != nil {
+		return err
+	}
+
+	if d.IgnitionConfig, err = loadIgnitionConfig(opts.String(flagIgnitionConfig)); err != nil {
+		return fmt.Errorf("failed to parse '--%s': %w", flagIgnitionConfig, err)
+	}
+
+	if d.Provisioner == provisionerIgnition {
+		return d.checkIgnitionFlags()
+	}
+
+	if d.IgnitionConfig != "" {
+		return fmt.Errorf("flag '--%s' requires flag '--%s' to be set to '%s'", flagIgnitionConfig, flagProvisioner, provisionerIgnition)
+	}
+
+	return d.checkCloudinitModeFlags()
+}
+
+// Checks that flags specific to cloud-init are not used with Ignition provisioner.
+func (d *Driver) checkIgnitionFlags() error {
+	cloudinitFlags := []struct {
+		name string
+		set  bool
+	}{
+		{flagCloudinitUserdata, d.CloudinitUserdata != ""},
+		{flagCloudinitMetadata, d.CloudinitMetadata != ""},
+		{flagTemplateVar, len(d.TemplateVars) > 0},
+		{flagIPPool, d.IPPool != nil},
+	}
+
+	for _, flag := range cloudinitFlags {
+		if flag.set {
+			return fmt.Errorf("flag '--%s' is not supported with '--%s=%s'", flag.name, flagProvisioner, provisionerIgnition)
+		}
+	}
+
+	// Proxmox VE cloud-init drive cannot carry custom user-data, Ignition config is passed on the ISO instead
+	if d.CloudinitMode == cloudinitModeNative {
+		return fmt.Errorf("flag '--%s=%s' is not supported with '--%s=%s'", flagCloudinitMode, cloudinitModeNative, flagProvisioner, provisionerIgnition)
+	}
+
+	for _, disk := range d.ExtraDisks {
+		if disk.MountPath != "" {
+			return fmt.Errorf("flag '--%s' with parameter 'mount' is not supported with '--%s=%s'", flagExtraDisk, flagProvisioner, provisionerIgnition)
+		}
+	}
+
+	return nil
+}
+
+// Checks that flags requiring custom cloud-init documents are not used with the native cloud-init mode.
+func (d *Driver) checkCloudinitModeFlags() error {
+	if d.CloudinitMode != cloudinitModeNative {
+		return nil
+	}
+
+	if d.CloudinitUserdata != "" {
+		return fmt.Errorf("flag '--%s' is not supported in '%s' cloud-init mode, use '--%s=%s'", flagCloudinitUserdata, cloudinitModeNative, flagCloudinitMode, cloudinitModeISO)
+	}
+
+	if d.CloudinitMetadata != "" {
+		return fmt.Errorf("flag '--%s' is not supported in '%s' cloud-init mode, use '--%s=%s'", flagCloudinitMetadata, cloudinitModeNative, flagCloudinitMode, cloudinitModeISO)
+	}
+
+	for _, disk := range d.ExtraDisks {
+		if disk.MountPath != "" {
+			return fmt.Errorf("flag '--%s' with parameter 'mount' is not supported in '%s' cloud-init mode, use '--%s=%s'", flagExtraDisk, cloudinitModeNative, flagCloudinitMode, cloudinitModeISO)
+		}
+	}
+
+	return nil
+}
+
+// Sets static IP address configuration from flags.
+func (d *Driver) setIPConfigFromFlags(opts drivers.DriverOptions) error {
+	d.IPPool = nil
+	d.IPGateway = strings.TrimSpace(opts.String(flagIPGateway))
+	d.IPNameservers = parseStringFlagToList(opts.String(flagIPNameservers))
+	d.IPSearchDomains = parseStringFlagToList(opts.String(flagIPSearchDomains))
+
+	if pool := strings.TrimSpace(opts.String(flagIPPool)); pool != "" {
+		var err error
+
+		if d.IPPool, err = parseIPPool(pool); err != nil {
+			return fmt.Errorf("failed to parse '--%s': %w", flagIPPool, err)
+		}
+	}
+
+	if d.IPPool == nil && (d.IPGateway != "" || len(d.IPNameservers) > 0 || len(d.IPSearchDomains) > 0) {
+		return fmt.Errorf("flags '--%s', '--%s' and '--%s' require flag '--%s' to be set", flagIPGateway, flagIPNameservers, flagIPSearchDomains, flagIPPool)
+	}
+
+	if d.IPGateway != "" {
+		if _, err := netip.ParseAddr(d.IPGateway); err != nil {
+			return fmt.Errorf("failed to parse '--%s': %w", flagIPGateway, err)
+		}
+	}
+
+	for _, nameserver := range d.IPNameservers {
+		if _, err := netip.ParseAddr(nameserver); err != nil {
+			return fmt.Errorf("failed to parse '--%s': %w", flagIPNameservers, err)
+		}
+	}
+
+	return nil
+}
+
+// Sets connection to Proxmox VE and the resource pool from flags.
+func (d *Driver) setConnectionFromFlags(opts drivers.DriverOptions) error {
+	d.URL = opts.String(flagURL)
+	if d.URL == "" {
+		return fmt.Errorf("flag '--%s' is required", flagURL)
+	}
+
+	if _, err := url.Parse(d.URL); err != nil {
+		return fmt.Errorf("failed to parse Proxmox VE URL (flag '--%s'): %w", flagURL, err)
+	}
+
+	d.InsecureTLS = opts.Bool(flagInsecureTLS)
+
+	d.TokenID = opts.String(flagTokenID)
+	if d.TokenID == "" {
+		return fmt.Errorf("flag '--%s' is required", flagTokenID)
+	}
+
+	d.TokenSecret = opts.String(flagTokenSecret)
+	if d.TokenSecret == "" {
+		return fmt.Errorf("flag '--%s' is required", flagTokenSecret)
+	}
+
+	d.ResourcePoolName = opts.String(flagResourcePool)
+	if d.ResourcePoolName == "" {
+		return fmt.Errorf("flag '--%s' is required", flagResourcePool)
+	}
+
 	return nil
 }
 
@@ -287,3 +858,124 @@ func parseStringFlagToInt(value string) (*int, error) {
 
 	return &numberValue, nil
 }
+
+// Sets timeouts of machine lifecycle phases and the polling interval from flags.
+func (d *Driver) setTimeoutsFromFlags(opts drivers.DriverOptions) error {
+	var err error
+
+	if d.PollingInterval, err = parseStringFlagToDuration(opts.String(flagPollingInterval), pveTaskPollingInterval); err != nil {
+		return fmt.Errorf("failed to parse '--%s': %w", flagPollingInterval, err)
+	}
+
+	timeouts := []struct {
+		flag  string
+		value *time.Duration
+	}{
+		{flagCloneTimeout, &d.CloneTimeout},
+		{flagConfigTimeout, &d.ConfigTimeout},
+		{flagStartTimeout, &d.StartTimeout},
+		{flagCloudinitTimeout, &d.CloudinitTimeout},
+		{flagDeleteTimeout, &d.DeleteTimeout},
+		{flagShutdownTimeout, &d.ShutdownTimeout},
+	}
+
+	for _, timeout := range timeouts {
+		if *timeout.value, err = parseStringFlagToDuration(opts.String(timeout.flag), pveTaskPollingTimeout); err != nil {
+			return fmt.Errorf("failed to parse '--%s': %w", timeout.flag, err)
+		}
+
+		if *timeout.value <= d.PollingInterval {
+			return fmt.Errorf("flag '--%s' must be longer than '--%s' (%s)", timeout.flag, flagPollingInterval, d.PollingInterval)
+		}
+	}
+
+	log.Debugf(
+		"Using timeouts: clone %s, config %s, start %s, cloud-init %s, delete %s, shutdown %s; polling interval %s",
+		d.CloneTimeout,
+		d.ConfigTimeout,
+		d.StartTimeout,
+		d.CloudinitTimeout,
+		d.DeleteTimeout,
+		d.ShutdownTimeout,
+		d.PollingInterval,
+	)
+
+	return nil
+}
+
+// Returns a given duration, or the default one if unset (e.g. in config of a machine created by older version of the driver).
+func durationOrDefault(value, defaultValue time.Duration) time.Duration {
+	if value <= 0 {
+		return defaultValue
+	}
+
+	return value
+}
+
+// Parses string flag to a positive duration. Returns the default value if the flag was unset/empty.
+func parseStringFlagToDuration(value string, defaultValue time.Duration) (time.Duration, error) {
+	trimmedValue := strings.TrimSpace(value)
+	if trimmedValue == "" {
+		return defaultValue, nil
+	}
+
+	duration, err := time.ParseDuration(trimmedValue)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert to duration: %w", err)
+	}
+
+	if duration < time.Second {
+		return 0, errors.New("duration must be >= 1s")
+	}
+
+	return duration, nil
+}
+
+// Parses comma-separated string flag to a list. Returns nil if the flag was unset/empty.
+func parseStringFlagToList(value string) []string {
+	var values []string
+
+	for _, item := range strings.Split(value, ",") {
+		if trimmedItem := strings.TrimSpace(item); trimmedItem != "" {
+			values = append(values, trimmedItem)
+		}
+	}
+
+	return values
+}
+
+// Parses range of IDs (e.g. '5000-5999'). Returns zeros if the flag was unset/empty.
+func parseVMIDRange(value string) (int, int, error) {
+	trimmedValue := strings.TrimSpace(value)
+	if trimmedValue == "" {
+		return 0, 0, nil
+	}
+
+	//nolint:mnd
+	bounds := strings.SplitN(trimmedValue, "-", 2)
+
+	//nolint:mnd
+	if len(bounds) != 2 {
+		return 0, 0, errors.New("range must be in format 'MIN-MAX'")
+	}
+
+	minID, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to convert lower bound to int: %w", err)
+	}
+
+	maxID, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to convert upper bound to int: %w", err)
+	}
+
+	if minID < pveMinVMID || maxID > pveMaxVMID {
+		return 0, 0, fmt.Errorf("range must be within %d-%d", pveMinVMID, pveMaxVMID)
+	}
+
+	if minID > maxID {
+		return 0, 0, errors.New("lower bound must be <= upper bound")
+	}
+
+	return minID, maxID, nil
+}
@@ -0,0 +1,116 @@
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseVMIDRange(t *testing.T) {
+	minID, maxID, err := parseVMIDRange(" 5000 - 5999 ")
+	require.NoError(t, err)
+	require.Equal(t, 5000, minID)
+	require.Equal(t, 5999, maxID)
+
+	minID, maxID, err = parseVMIDRange("")
+	require.NoError(t, err)
+	require.Zero(t, minID)
+	require.Zero(t, maxID)
+
+	for _, value := range []string{"5000", "5000-", "a-b", "99-200", "200-100", "100-1000000000"} {
+		t.Run(value, func(t *testing.T) {
+			_, _, err := parseVMIDRange(value)
+			require.Error(t, err)
+		})
+	}
+}
+
+func Test_parseStringFlagToDuration(t *testing.T) {
+	duration, err := parseStringFlagToDuration(" 90s ", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 90*time.Second, duration)
+
+	duration, err = parseStringFlagToDuration("", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, duration)
+
+	for _, value := range []string{"90", "abc", "0s", "-1m", "500ms"} {
+		t.Run(value, func(t *testing.T) {
+			_, err := parseStringFlagToDuration(value, time.Minute)
+			require.Error(t, err)
+		})
+	}
+}
+
+// Driver options with values given by flag name.
+type stubDriverOptions map[string]interface{}
+
+func (o stubDriverOptions) String(key string) string {
+	value, _ := o[key].(string)
+	return value
+}
+
+func (o stubDriverOptions) StringSlice(key string) []string {
+	value, _ := o[key].([]string)
+	return value
+}
+
+func (o stubDriverOptions) Int(key string) int {
+	value, _ := o[key].(int)
+	return value
+}
+
+func (o stubDriverOptions) Bool(key string) bool {
+	value, _ := o[key].(bool)
+	return value
+}
+
+func Test_setTimeoutsFromFlags(t *testing.T) {
+	d := NewDriver("machine", t.TempDir())
+
+	require.NoError(t, d.setTimeoutsFromFlags(stubDriverOptions{
+		flagCloneTimeout:    "45m",
+		flagPollingInterval: "1s",
+	}))
+	require.Equal(t, 45*time.Minute, d.CloneTimeout)
+	require.Equal(t, pveTaskPollingTimeout, d.ConfigTimeout)
+	require.Equal(t, pveTaskPollingTimeout, d.DeleteTimeout)
+	require.Equal(t, time.Second, d.PollingInterval)
+
+	for name, opts := range map[string]stubDriverOptions{
+		"invalid timeout":           {flagStartTimeout: "soon"},
+		"invalid interval":          {flagPollingInterval: "0s"},
+		"timeout shorter than poll": {flagCloudinitTimeout: "5s", flagPollingInterval: "10s"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Error(t, d.setTimeoutsFromFlags(opts))
+		})
+	}
+}
+
+func Test_checkCloudinitModeFlags(t *testing.T) {
+	d := NewDriver("machine", "")
+	d.CloudinitMode = cloudinitModeNative
+	d.CloudinitUserdata = "packages: [htop]"
+
+	require.Error(t, d.checkCloudinitModeFlags())
+
+	d.CloudinitUserdata = ""
+	d.ExtraDisks = []extraDisk{{MountPath: "/var/lib/longhorn"}}
+	require.Error(t, d.checkCloudinitModeFlags())
+
+	d.CloudinitMode = cloudinitModeISO
+	require.NoError(t, d.checkCloudinitModeFlags())
+}
+
+func Test_checkIgnitionFlags(t *testing.T) {
+	d := NewDriver("machine", "")
+	d.Provisioner = provisionerIgnition
+	d.CloudinitMode = cloudinitModeISO
+
+	require.NoError(t, d.checkIgnitionFlags())
+
+	d.CloudinitMode = cloudinitModeNative
+	require.Error(t, d.checkIgnitionFlags())
+}
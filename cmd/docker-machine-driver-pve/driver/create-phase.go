@@ -0,0 +1,128 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/rancher/machine/libmachine/log"
+)
+
+// Phases of the machine creation recorded in the driver state.
+// Cloud-init phases are shared with Ignition.
+const (
+	createPhaseCloned             = "cloned"
+	createPhaseTagged             = "tagged"
+	createPhaseHardwareConfigured = "hardware-configured"
+	createPhaseCloudinitAttached  = "cloudinit-attached"
+	createPhaseStarted            = "started"
+	createPhaseCloudinitDone      = "cloudinit-done"
+	createPhaseCleaned            = "cleaned"
+)
+
+// Phases of the machine creation, in order.
+var createPhases = []string{
+	createPhaseCloned,
+	createPhaseTagged,
+	createPhaseHardwareConfigured,
+	createPhaseCloudinitAttached,
+	createPhaseStarted,
+	createPhaseCloudinitDone,
+	createPhaseCleaned,
+}
+
+// Name of the file with the driver state persisted during the machine creation, in the machine's directory.
+const createStateFileName = "pve-create-state.json"
+
+// Returns whether a given phase of the machine creation was already completed.
+func (d *Driver) isCreatePhaseDone(phase string) bool {
+	return slices.Index(createPhases, d.CreatePhase) >= slices.Index(createPhases, phase)
+}
+
+// Returns whether the current machine was cloned, but its creation did not finish.
+// Machines created by older versions of the driver have no phase and are treated as complete.
+func (d *Driver) isCreateIncomplete() bool {
+	return d.PVEMachineID != nil && d.CreatePhase != "" && d.CreatePhase != createPhaseCleaned
+}
+
+// Records a completed phase of the machine creation and persists the driver state,
+// so the creation can continue from the phase if the process dies.
+func (d *Driver) setCreatePhase(phase string) error {
+	d.CreatePhase = phase
+
+	log.Debugf("Machine creation reached phase '%s'", phase)
+
+	if phase == createPhaseCleaned {
+		return d.deleteCreateState()
+	}
+
+	return d.saveCreateState()
+}
+
+// Persists the driver state of the machine being created.
+func (d *Driver) saveCreateState() error {
+	content, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("failed to marshal driver state: %w", err)
+	}
+
+	path := d.ResolveStorePath(createStateFileName)
+
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		return fmt.Errorf("failed to write driver state '%s': %w", path, err)
+	}
+
+	return nil
+}
+
+// Loads the driver state persisted by an unfinished machine creation, if any.
+// Returns whether the creation is incomplete and should be resumed.
+func (d *Driver) loadCreateState() (bool, error) {
+	path := d.ResolveStorePath(createStateFileName)
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to read driver state '%s': %w", path, err)
+	}
+
+	// Store might have been moved since the state was persisted
+	storePath, machineName := d.StorePath, d.MachineName
+
+	if err := json.Unmarshal(content, d); err != nil {
+		return false, fmt.Errorf("failed to parse driver state '%s': %w", path, err)
+	}
+
+	d.StorePath, d.MachineName = storePath, machineName
+
+	return d.isCreateIncomplete(), nil
+}
+
+// Deletes the persisted driver state of the machine creation.
+// Machine without machine store (e.g. orphaned machine) has no state.
+func (d *Driver) deleteCreateState() error {
+	if d.StorePath == "" {
+		return nil
+	}
+
+	path := d.ResolveStorePath(createStateFileName)
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete driver state '%s': %w", path, err)
+	}
+
+	return nil
+}
+
+// Step of the machine creation, completing a given phase.
+type createStep struct {
+	phase   string
+	message string
+	run     func(ctx context.Context) error
+}
@@ -0,0 +1,53 @@
+package driver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_isCreatePhaseDone(t *testing.T) {
+	d := NewDriver("machine", "")
+	require.False(t, d.isCreatePhaseDone(createPhaseCloned))
+
+	d.CreatePhase = createPhaseHardwareConfigured
+	require.True(t, d.isCreatePhaseDone(createPhaseCloned))
+	require.True(t, d.isCreatePhaseDone(createPhaseTagged))
+	require.True(t, d.isCreatePhaseDone(createPhaseHardwareConfigured))
+	require.False(t, d.isCreatePhaseDone(createPhaseCloudinitAttached))
+	require.False(t, d.isCreatePhaseDone(createPhaseCleaned))
+}
+
+func Test_loadCreateState(t *testing.T) {
+	storePath := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(storePath, "machines", "machine"), 0o700))
+
+	d := NewDriver("machine", storePath)
+
+	resume, err := d.loadCreateState()
+	require.NoError(t, err)
+	require.False(t, resume)
+
+	vmid := 100
+	d.PVEMachineID = &vmid
+	d.IPAddress = "10.20.0.10"
+	require.NoError(t, d.setCreatePhase(createPhaseTagged))
+
+	loaded := NewDriver("machine", storePath)
+
+	resume, err = loaded.loadCreateState()
+	require.NoError(t, err)
+	require.True(t, resume)
+	require.Equal(t, vmid, *loaded.PVEMachineID)
+	require.Equal(t, "10.20.0.10", loaded.IPAddress)
+	require.Equal(t, createPhaseTagged, loaded.CreatePhase)
+
+	require.NoError(t, d.setCreatePhase(createPhaseCleaned))
+	require.NoFileExists(t, d.ResolveStorePath(createStateFileName))
+
+	resume, err = NewDriver("machine", storePath).loadCreateState()
+	require.NoError(t, err)
+	require.False(t, resume)
+}
@@ -0,0 +1,412 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/rancher/machine/libmachine/log"
+)
+
+// Resizes the root disk of the current machine.
+func (d *Driver) setupDisk(ctx context.Context) error {
+	if d.DiskSize == "" {
+		return nil
+	}
+
+	machine, err := d.getCurrentMachine(ctx)
+	if err != nil {
+		return err
+	}
+
+	deviceName, resize, err := d.checkDiskResize(machine)
+	if err != nil {
+		return err
+	}
+
+	if !resize {
+		log.Debugf("Disk '%s' already has size %s, skipping resize", deviceName, d.DiskSize)
+		return nil
+	}
+
+	err = d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+		var upid proxmox.UPID
+
+		err := d.getPVEClient().Put(ctx, fmt.Sprintf("/nodes/%s/qemu/%d/resize", vm.Node, vm.VMID), map[string]string{
+			"disk": deviceName,
+			"size": d.DiskSize,
+		}, &upid)
+
+		return proxmox.NewTask(upid, d.getPVEClient()), err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to resize disk '%s' to %s: %w", deviceName, d.DiskSize, err)
+	}
+
+	return nil
+}
+
+// Checks that the disk of a given virtual machine can be resized to the configured size.
+// Returns name of the disk device and whether the resize is needed.
+func (d *Driver) checkDiskResize(vm *proxmox.VirtualMachine) (string, bool, error) {
+	deviceName := d.DiskDeviceName
+	if deviceName == "" {
+		deviceName = getBootDiskDeviceName(vm.VirtualMachineConfig)
+	}
+
+	if deviceName == "" {
+		return "", false, fmt.Errorf("failed to determine boot disk of Proxmox VE virtual machine ID='%d', set it explicitly with '--%s'", vm.VMID, flagDiskDevice)
+	}
+
+	deviceConfig, deviceFound := vm.VirtualMachineConfig.MergeDisks()[deviceName]
+	if !deviceFound {
+		return "", false, fmt.Errorf("disk '%s' not found on Proxmox VE virtual machine ID='%d'", deviceName, vm.VMID)
+	}
+
+	if strings.Contains(deviceConfig, "media=cdrom") {
+		return "", false, fmt.Errorf("device '%s' is a CD/DVD drive and can not be resized", deviceName)
+	}
+
+	currentSize, err := parseDiskSize(getParamFromPveDevice(deviceConfig, "size"))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to retrieve current size of disk '%s': %w", deviceName, err)
+	}
+
+	requestedSize, err := parseDiskSize(d.DiskSize)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to parse requested disk size: %w", err)
+	}
+
+	if requestedSize < currentSize {
+		return "", false, fmt.Errorf(
+			"requested size %s of disk '%s' is smaller than its current size %s, shrinking disks is not supported",
+			d.DiskSize,
+			deviceName,
+			getParamFromPveDevice(deviceConfig, "size"),
+		)
+	}
+
+	return deviceName, requestedSize > currentSize, nil
+}
+
+// Returns name of the first disk in the boot order, or empty string if it can not be determined.
+func getBootDiskDeviceName(config *proxmox.VirtualMachineConfig) string {
+	if config == nil {
+		return ""
+	}
+
+	disks := config.MergeDisks()
+
+	for _, deviceName := range strings.Split(getParamFromPveDevice(config.Boot, "order"), ";") {
+		deviceConfig, deviceFound := disks[deviceName]
+		if deviceFound && !strings.Contains(deviceConfig, "media=cdrom") {
+			return deviceName
+		}
+	}
+
+	return ""
+}
+
+// Parses disk size in Proxmox VE format (e.g. '64G') to bytes.
+func parseDiskSize(size string) (uint64, error) {
+	units := map[byte]uint64{
+		'K': 1 << 10, //nolint:mnd
+		'M': 1 << 20, //nolint:mnd
+		'G': 1 << 30, //nolint:mnd
+		'T': 1 << 40, //nolint:mnd
+	}
+
+	size = strings.ToUpper(strings.TrimSpace(size))
+	if size == "" {
+		return 0, errors.New("size is empty")
+	}
+
+	// Proxmox VE treats '+' as growth relative to the current size, which a resumed creation would apply again
+	if strings.HasPrefix(size, "+") || strings.HasPrefix(size, "-") {
+		return 0, fmt.Errorf("relative size '%s' is not supported, use absolute size (e.g. '64G')", size)
+	}
+
+	multiplier := uint64(1)
+	if unitMultiplier, ok := units[size[len(size)-1]]; ok {
+		multiplier = unitMultiplier
+		size = size[:len(size)-1]
+	}
+
+	// Float syntax other than decimal digits (e.g. 'INF', '1E3') is not accepted by Proxmox VE
+	if strings.ContainsFunc(size, func(r rune) bool { return (r < '0' || r > '9') && r != '.' }) {
+		return 0, fmt.Errorf("invalid size '%s'", size)
+	}
+
+	value, err := strconv.ParseFloat(size, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid size '%s'", size)
+	}
+
+	return uint64(value * float64(multiplier)), nil
+}
+
+// Maximum number of devices on each disk bus.
+var diskBusDeviceCounts = map[string]int{
+	"ide":    4,
+	"sata":   6,
+	"scsi":   31,
+	"virtio": 16,
+}
+
+// Additional data disk attached to the machine.
+type extraDisk struct {
+	// Bus to attach the disk to (e.g. 'scsi').
+	Bus string
+
+	// Proxmox VE storage to allocate the disk on.
+	Storage string
+
+	// Size of the disk in GiB.
+	SizeGiB int
+
+	// If set, cache mode of the disk (e.g. 'none', 'writeback').
+	Cache string
+
+	// Passes discard/trim requests to the underlying storage.
+	Discard bool
+
+	// Presents the disk to the guest as a solid-state drive.
+	SSD bool
+
+	// Uses a dedicated I/O thread for the disk.
+	IOThread bool
+
+	// If set, path to mount the disk at, the disk is formatted via cloud-init.
+	MountPath string
+
+	// Filesystem to format the disk with, used only if the mount path is set.
+	Filesystem string
+
+	// Bus/Device the disk was attached to (e.g. 'scsi1'), set once the disk is created.
+	DeviceName string
+}
+
+// Parses extra disk specification (e.g. 'storage=local-lvm,size=100G,mount=/var/lib/longhorn').
+//
+//nolint:cyclop
+func parseExtraDisk(spec string) (extraDisk, error) {
+	disk := extraDisk{
+		Bus:        "scsi",
+		Filesystem: "ext4",
+	}
+
+	for _, param := range strings.Split(spec, ",") {
+		if strings.TrimSpace(param) == "" {
+			continue
+		}
+
+		//nolint:mnd
+		values := strings.SplitN(param, "=", 2)
+
+		//nolint:mnd
+		if len(values) != 2 {
+			return disk, fmt.Errorf("invalid parameter '%s', expected 'key=value'", param)
+		}
+
+		key, value := strings.ToLower(strings.TrimSpace(values[0])), strings.TrimSpace(values[1])
+
+		var err error
+
+		switch key {
+		case "bus":
+			disk.Bus = strings.ToLower(value)
+		case "storage":
+			disk.Storage = value
+		case "size":
+			disk.SizeGiB, err = parseDiskSizeToGiB(value)
+		case "cache":
+			disk.Cache = value
+		case "discard":
+			disk.Discard, err = parseDiskBool(value)
+		case "ssd":
+			disk.SSD, err = parseDiskBool(value)
+		case "iothread":
+			disk.IOThread, err = parseDiskBool(value)
+		case "mount":
+			disk.MountPath = value
+		case "fs":
+			disk.Filesystem = value
+		default:
+			return disk, fmt.Errorf("unknown parameter '%s'", key)
+		}
+
+		if err != nil {
+			return disk, fmt.Errorf("invalid value of parameter '%s': %w", key, err)
+		}
+	}
+
+	if _, ok := diskBusDeviceCounts[disk.Bus]; !ok {
+		return disk, fmt.Errorf("bus '%s' is not supported, use 'ide', 'sata', 'scsi' or 'virtio'", disk.Bus)
+	}
+
+	if disk.Storage == "" {
+		return disk, errors.New("parameter 'storage' is required")
+	}
+
+	if disk.SizeGiB == 0 {
+		return disk, errors.New("parameter 'size' is required")
+	}
+
+	if disk.SSD && disk.Bus == "virtio" {
+		return disk, errors.New("parameter 'ssd' is not supported on 'virtio' bus")
+	}
+
+	if disk.IOThread && disk.Bus != "scsi" && disk.Bus != "virtio" {
+		return disk, errors.New("parameter 'iothread' is supported only on 'scsi' and 'virtio' buses")
+	}
+
+	if disk.MountPath != "" && !strings.HasPrefix(disk.MountPath, "/") {
+		return disk, fmt.Errorf("mount path '%s' must be absolute", disk.MountPath)
+	}
+
+	return disk, nil
+}
+
+// Returns Proxmox VE configuration allocating the disk (e.g. 'local-lvm:100,discard=on,serial=dm-scsi1').
+func (disk *extraDisk) getPVEConfig() string {
+	params := []string{
+		fmt.Sprintf("%s:%d", disk.Storage, disk.SizeGiB),
+	}
+
+	if disk.Cache != "" {
+		params = append(params, "cache="+disk.Cache)
+	}
+
+	if disk.Discard {
+		params = append(params, "discard=on")
+	}
+
+	if disk.SSD {
+		params = append(params, "ssd=1")
+	}
+
+	if disk.IOThread {
+		params = append(params, "iothread=1")
+	}
+
+	params = append(params, "serial="+disk.getSerial())
+
+	return strings.Join(params, ",")
+}
+
+// Returns serial number of the disk, used to find the disk within the guest.
+func (disk *extraDisk) getSerial() string {
+	return "dm-" + disk.DeviceName
+}
+
+// Returns stable path of the disk within the guest.
+func (disk *extraDisk) getGuestDevicePath() string {
+	switch disk.Bus {
+	case "virtio":
+		return "/dev/disk/by-id/virtio-" + disk.getSerial()
+	case "scsi":
+		return "/dev/disk/by-id/scsi-0QEMU_QEMU_HARDDISK_" + disk.getSerial()
+	default:
+		return "/dev/disk/by-id/ata-QEMU_HARDDISK_" + disk.getSerial()
+	}
+}
+
+// Allocates and attaches extra disks to the current machine.
+// Disks already attached by an interrupted creation of the machine are reused.
+func (d *Driver) setupExtraDisks(ctx context.Context) error {
+	if len(d.ExtraDisks) == 0 {
+		return nil
+	}
+
+	machine, err := d.getCurrentMachine(ctx)
+	if err != nil {
+		return err
+	}
+
+	usedDeviceNames := machine.VirtualMachineConfig.MergeDisks()
+	usedDeviceNames[d.ISODeviceName] = ""
+
+	options := make([]proxmox.VirtualMachineOption, 0, len(d.ExtraDisks))
+
+	for index := range d.ExtraDisks {
+		disk := &d.ExtraDisks[index]
+
+		disk.DeviceName = ""
+		attached := false
+
+		for deviceIndex := range diskBusDeviceCounts[disk.Bus] {
+			deviceName := fmt.Sprintf("%s%d", disk.Bus, deviceIndex)
+			deviceConfig, used := usedDeviceNames[deviceName]
+
+			if !used || strings.Contains(deviceConfig, "serial=dm-"+deviceName) {
+				disk.DeviceName = deviceName
+				usedDeviceNames[deviceName] = ""
+				attached = used
+
+				break
+			}
+		}
+
+		if disk.DeviceName == "" {
+			return fmt.Errorf("failed to attach extra disk: no free device on '%s' bus", disk.Bus)
+		}
+
+		if attached {
+			log.Debugf("Extra disk '%s' is already attached", disk.DeviceName)
+			continue
+		}
+
+		log.Debugf("Attaching extra disk of %dG from storage '%s' as '%s'", disk.SizeGiB, disk.Storage, disk.DeviceName)
+
+		options = append(options, proxmox.VirtualMachineOption{
+			Name:  disk.DeviceName,
+			Value: disk.getPVEConfig(),
+		})
+	}
+
+	if len(options) == 0 {
+		return nil
+	}
+
+	err = d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+		return vm.Config(ctx, options...)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to attach extra disks: %w", err)
+	}
+
+	return nil
+}
+
+// Parses disk size in Proxmox VE format (e.g. '100G') to whole GiB.
+func parseDiskSizeToGiB(size string) (int, error) {
+	if _, err := strconv.Atoi(strings.TrimSpace(size)); err == nil {
+		size += "G"
+	}
+
+	bytes, err := parseDiskSize(size)
+	if err != nil {
+		return 0, err
+	}
+
+	if bytes%(1<<30) != 0 {
+		return 0, fmt.Errorf("size '%s' is not a whole number of GiB", size)
+	}
+
+	return int(bytes >> 30), nil //nolint:gosec,mnd
+}
+
+// Parses boolean value of a disk parameter (e.g. 'on', '1', 'true').
+func parseDiskBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "1", "on", "true", "yes":
+		return true, nil
+	case "0", "off", "false", "no":
+		return false, nil
+	default:
+		return false, fmt.Errorf("'%s' is not a boolean", value)
+	}
+}
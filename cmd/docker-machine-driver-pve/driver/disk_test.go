@@ -0,0 +1,106 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseDiskSize(t *testing.T) {
+	tests := map[string]uint64{
+		"1024": 1024,
+		"512K": 512 << 10,
+		"512M": 512 << 20,
+		"64G":  64 << 30,
+		"64g":  64 << 30,
+		"1.5T": 3 << 39,
+	}
+
+	for size, expectedBytes := range tests {
+		t.Run(size, func(t *testing.T) {
+			bytes, err := parseDiskSize(size)
+			require.NoError(t, err)
+			require.Equal(t, expectedBytes, bytes)
+		})
+	}
+
+	for _, size := range []string{"", "G", "-1G", "+10G", "64X", "sixty", "inf", "1e3G"} {
+		t.Run(size, func(t *testing.T) {
+			_, err := parseDiskSize(size)
+			require.Error(t, err)
+		})
+	}
+}
+
+func Test_getBootDiskDeviceName(t *testing.T) {
+	tests := map[string]struct {
+		config       proxmox.VirtualMachineConfig
+		expectedDisk string
+	}{
+		"boot order": {
+			config: proxmox.VirtualMachineConfig{
+				Boot:  "order=scsi1;scsi0;net0",
+				SCSI0: "local-lvm:vm-100-disk-0,size=32G",
+				SCSI1: "local-lvm:vm-100-disk-1,size=8G",
+			},
+			expectedDisk: "scsi1",
+		},
+		"CD/DVD drive first": {
+			config: proxmox.VirtualMachineConfig{
+				Boot:    "order=ide2;virtio0",
+				IDE2:    "none,media=cdrom",
+				VirtIO0: "local-lvm:vm-100-disk-0,size=32G",
+			},
+			expectedDisk: "virtio0",
+		},
+		"no boot order": {
+			config: proxmox.VirtualMachineConfig{
+				SCSI0: "local-lvm:vm-100-disk-0,size=32G",
+			},
+			expectedDisk: "",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, test.expectedDisk, getBootDiskDeviceName(&test.config))
+		})
+	}
+}
+
+func Test_parseExtraDisk(t *testing.T) {
+	disk, err := parseExtraDisk("storage=local-lvm,size=100G,cache=none,discard=on,ssd=1,iothread=true,mount=/var/lib/longhorn")
+	require.NoError(t, err)
+	require.Equal(t, extraDisk{
+		Bus:        "scsi",
+		Storage:    "local-lvm",
+		SizeGiB:    100,
+		Cache:      "none",
+		Discard:    true,
+		SSD:        true,
+		IOThread:   true,
+		MountPath:  "/var/lib/longhorn",
+		Filesystem: "ext4",
+	}, disk)
+
+	disk.DeviceName = "scsi2"
+	require.Equal(t, "local-lvm:100,cache=none,discard=on,ssd=1,iothread=1,serial=dm-scsi2", disk.getPVEConfig())
+	require.Equal(t, "/dev/disk/by-id/scsi-0QEMU_QEMU_HARDDISK_dm-scsi2", disk.getGuestDevicePath())
+
+	for _, spec := range []string{
+		"size=10G",
+		"storage=local-lvm",
+		"storage=local-lvm,size=1.5G",
+		"storage=local-lvm,size=10G,bus=usb",
+		"storage=local-lvm,size=10G,bus=virtio,ssd=1",
+		"storage=local-lvm,size=10G,bus=sata,iothread=1",
+		"storage=local-lvm,size=10G,mount=data",
+		"storage=local-lvm,size=10G,unknown=1",
+	} {
+		t.Run(spec, func(t *testing.T) {
+			_, err := parseExtraDisk(spec)
+			require.Error(t, err)
+		})
+	}
+}
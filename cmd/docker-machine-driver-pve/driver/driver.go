@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"os"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,8 +29,17 @@ type Driver struct {
 	// Cached client for the Proxmox VE.
 	pveClient *proxmox.Client
 
+	// Root context of driver operations, cancelled when the process is interrupted.
+	ctx context.Context
+
 	// Proxmox VE ID of the current machine.
 	PVEMachineID *int
+
+	// Static IP address allocated to the current machine, empty if DHCP is used.
+	IPAddress string
+
+	// Last completed phase of the machine creation, empty for machines created before phases were recorded.
+	CreatePhase string
 }
 
 // Creates a new driver.
@@ -42,21 +53,84 @@ func NewDriver(machineName, storePath string) *Driver {
 	}
 }
 
+// Sets the root context of driver operations.
+// Operations in progress are interrupted once the context is cancelled, and machine being created is removed.
+func (d *Driver) SetContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+// Returns the root context of driver operations.
+func (d *Driver) getContext() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+
+	return d.ctx
+}
+
 // PreCreateCheck implements drivers.Driver.
 func (d *Driver) PreCreateCheck() error {
+	ctx := d.getContext()
+
 	// Check resource pool
-	resourcePool, err := d.getCurrentPVEResourcePool(context.TODO())
+	resourcePool, err := d.getCurrentPVEResourcePool(ctx)
 	if err != nil {
 		return err
 	}
 
 	// Check template
-	template, err := d.getPVETemplate(context.TODO())
+	template, err := d.getPVETemplate(ctx)
 	if err != nil {
 		return err
 	}
 
-	// Check ISO device
+	// Check device the provisioner configuration is passed through
+	switch {
+	case d.CloudinitMode == cloudinitModeNative:
+		cloudinitDriveDeviceName := getCloudinitDriveDeviceName(template.VirtualMachineConfig)
+		if cloudinitDriveDeviceName == "" {
+			return fmt.Errorf("cloud-init drive not found on the template, add one or use '--%s=%s'", flagCloudinitMode, cloudinitModeISO)
+		}
+
+		log.Debugf("Using cloud-init drive '%s'", cloudinitDriveDeviceName)
+	default:
+		if err := d.checkISODevice(template); err != nil {
+			return err
+		}
+
+		log.Debugf("Using device '%s' for %s ISO", d.ISODeviceName, d.Provisioner)
+	}
+
+	// Check network interface
+	_, networkInterfaceFound := template.VirtualMachineConfig.MergeNets()[d.NetworkInterfaceName]
+	if !networkInterfaceFound {
+		return fmt.Errorf("network interface '%s' not found on the template", d.NetworkInterfaceName)
+	}
+
+	// Check disk resize
+	if d.DiskSize != "" {
+		diskDeviceName, _, err := d.checkDiskResize(template)
+		if err != nil {
+			return err
+		}
+
+		log.Debugf("Using disk '%s' for resize to %s", diskDeviceName, d.DiskSize)
+	}
+
+	// Check placement of the machine
+	if err := d.checkPlacement(ctx, template); err != nil {
+		return err
+	}
+
+	log.Debugf("Using resource pool '%s'", resourcePool.PoolID)
+	log.Debugf("Using template name '%s' ID='%d' on node '%s'", template.Name, d.TemplateID, template.Node)
+	log.Debugf("Using network interface '%s' for IP address", d.NetworkInterfaceName)
+
+	return nil
+}
+
+// Checks the CD/DVD drive of a given template can be used for cloud-init ISO.
+func (d *Driver) checkISODevice(template *proxmox.VirtualMachine) error {
 	var (
 		isoDeviceConfig string
 		isoDeviceFound  bool
@@ -81,40 +155,110 @@ func (d *Driver) PreCreateCheck() error {
 		return errors.New("cloud-init ISO device must be of type media=cdrom")
 	}
 
-	// Check network interface
-	_, networkInterfaceFound := template.VirtualMachineConfig.MergeNets()[d.NetworkInterfaceName]
-	if !networkInterfaceFound {
-		return fmt.Errorf("network interface '%s' not found on the template", d.NetworkInterfaceName)
-	}
-
-	log.Debugf("Using resource pool '%s'", resourcePool.PoolID)
-	log.Debugf("Using template name '%s' on node '%s'", template.Name, template.Node)
-	log.Debugf("Using device '%s' for cloud-init ISO", d.ISODeviceName)
-	log.Debugf("Using network interface '%s' for IP address", d.NetworkInterfaceName)
-
 	return nil
 }
 
 // Create implements drivers.Driver.
+// Creation interrupted by death of the process is resumed from the last completed phase.
 func (d *Driver) Create() error {
-	log.Info("Generating SSH keys...")
+	ctx := d.getContext()
+
+	resume, err := d.loadCreateState()
+	if err != nil {
+		return err
+	}
+
+	// Key pair of an interrupted creation is already passed to the machine
+	if !resume {
+		log.Info("Generating SSH keys...")
+
+		if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+			return fmt.Errorf("failed to generate SSH key pair: %w", err)
+		}
+	}
+
+	// Template errors must fail before anything is created, templates may use the public key
+	if err := d.checkCloudinitTemplates(); err != nil {
+		return fmt.Errorf("failed to render cloud-init templates: %w", err)
+	}
+
+	if resume {
+		log.Infof("Resuming creation of the machine ID='%d' after phase '%s'...", *d.PVEMachineID, d.CreatePhase)
+	} else {
+		if err := d.clone(ctx); err != nil {
+			return err
+		}
+
+		// Machine is recorded right away, so that it is not orphaned if the process dies
+		err = d.setCreatePhase(createPhaseCloned)
+	}
+
+	if err == nil {
+		err = d.initialize(ctx)
+	}
+
+	if err == nil && d.InitialSnapshot != "" {
+		log.Info("Taking initial snapshot...")
+
+		err = d.CreateSnapshot(ctx, d.InitialSnapshot, "Clean state of the machine after initialization by Docker Machine driver", false)
+	}
+
+	if err != nil {
+		// Removal must not be interrupted by cancellation of the creation, but it must not block forever either
+		cleanupTimeout := durationOrDefault(d.DeleteTimeout, pveTaskPollingTimeout)
+
+		// Removal of interrupted creation is best-effort, since the process is about to be killed
+		if ctx.Err() != nil {
+			log.Warn("Creation of the machine was interrupted, removing the machine...")
+
+			cleanupTimeout = pveInterruptedCleanupTimeout
+		}
 
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
-		return fmt.Errorf("failed to generate SSH key pair: %w", err)
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+		defer cancel()
+
+		// Protection of uninitialized machine is not honored, since nothing depends on it yet
+		if removeErr := d.remove(cleanupCtx, true); removeErr != nil {
+			return fmt.Errorf("failed to initialize the machine: %w; failed to remove uninitialized machine, remove it with the gc command: %w", err, removeErr)
+		}
+
+		return fmt.Errorf("failed to initialize the machine: %w; machine was removed successfully", err)
 	}
 
+	return nil
+}
+
+// Clones the template into the current machine.
+func (d *Driver) clone(ctx context.Context) error {
 	log.Info("Creating the machine...")
 
 	retryBackoff := 1 // seconds
+	conflictingIDs := map[int]bool{}
 
 	for {
-		vmid, err := d.createPVEVirtualMachine(context.TODO())
+		cloneCtx, cancel := context.WithTimeout(ctx, durationOrDefault(d.CloneTimeout, pveTaskPollingTimeout))
+		vmid, err := d.createPVEVirtualMachine(cloneCtx, conflictingIDs)
+
+		cancel()
+
 		if err != nil {
-			if strings.Contains(err.Error(), "config file already exists") {
+			if isPVEVirtualMachineIDConflict(err) && d.VMIDRangeMin != 0 {
+				log.Warnf("Hit ID conflict on ID='%d' when cloning the machine, will retry with the next free ID...", vmid)
+
+				conflictingIDs[vmid] = true
+
+				continue
+			}
+
+			if isPVEVirtualMachineIDConflict(err) {
 				log.Warn("Hit ID conflict when cloning the machine, will retry...")
 
 				//nolint:gosec // Weak number generator is good enough for this case
-				<-time.After(time.Duration(retryBackoff+rand.Intn(retryBackoff)) * time.Second)
+				select {
+				case <-ctx.Done():
+					return fmt.Errorf("failed to create machine: %w", ctx.Err())
+				case <-time.After(time.Duration(retryBackoff+rand.Intn(retryBackoff)) * time.Second):
+				}
 
 				// Double the backoff for next iteration
 				retryBackoff *= 2
@@ -134,81 +278,120 @@ func (d *Driver) Create() error {
 		break
 	}
 
-	if err := d.initialize(); err != nil {
-		if removeErr := d.Remove(); removeErr != nil {
-			return fmt.Errorf("failed to initialize the machine: %w; failed to remove uninitialized machine: %w", err, removeErr)
+	return nil
+}
+
+// Initializes the current machine.
+// Phases already completed by an interrupted creation of the machine are skipped.
+func (d *Driver) initialize(ctx context.Context) error {
+	steps := []createStep{
+		{createPhaseTagged, "Tagging the machine...", d.setupTags},
+		{createPhaseHardwareConfigured, "Configuring machine hardware...", d.setupHardware},
+	}
+
+	if d.Provisioner == provisionerIgnition {
+		steps = append(steps,
+			createStep{createPhaseCloudinitAttached, "Configuring Ignition...", d.setupIgnition},
+			createStep{createPhaseStarted, "Starting the machine...", d.startForInitialization},
+			createStep{createPhaseCloudinitDone, "Waiting for first boot to finish...", func(ctx context.Context) error {
+				if err := d.waitForIgnition(ctx); err != nil {
+					return fmt.Errorf("failed waiting for first boot to finish: %w", err)
+				}
+
+				return nil
+			}},
+			createStep{createPhaseCleaned, "Cleaning up...", d.cleanupCloudinit},
+		)
+	} else {
+		steps = append(steps,
+			createStep{createPhaseCloudinitAttached, "Configuring cloud-init...", d.setupCloudinit},
+			createStep{createPhaseStarted, "Starting the machine...", d.startForInitialization},
+			createStep{createPhaseCloudinitDone, "Waiting for cloud-init to finish...", func(ctx context.Context) error {
+				if err := d.waitForCloudinit(ctx); err != nil {
+					return fmt.Errorf("failed waiting for cloud-init to finish: %w", err)
+				}
+
+				return nil
+			}},
+			createStep{createPhaseCleaned, "Cleaning up...", d.cleanupCloudinit},
+		)
+	}
+
+	for _, step := range steps {
+		if d.isCreatePhaseDone(step.phase) {
+			log.Debugf("Skipping phase '%s' completed before", step.phase)
+			continue
 		}
 
-		return fmt.Errorf("failed to initialize the machine: %w; machine was removed successfully", err)
+		log.Info(step.message)
+
+		if err := step.run(ctx); err != nil {
+			return err
+		}
+
+		if err := d.setCreatePhase(step.phase); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// Initializes the current machine.
-func (d *Driver) initialize() error {
-	log.Info("Tagging the machine...")
-
-	machine, err := d.getPVEVirtualMachine(context.TODO(), *d.PVEMachineID)
+// Tags the current machine as managed by the driver and allocates its static IP address.
+// Tags already present on the machine are kept.
+func (d *Driver) setupTags(ctx context.Context) error {
+	// Newly cloned machine is not the current machine until it has the driver's tag
+	machine, err := d.getPVEVirtualMachine(ctx, *d.PVEMachineID)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve newly created Proxmox VE virtual machine ID='%d': %w", *d.PVEMachineID, err)
 	}
 
-	tagTask, err := machine.AddTag(context.TODO(), pveMachineTag)
+	tagTask, err := machine.AddTag(ctx, pveMachineTag)
 
 	if err == nil {
-		err = d.waitForPVETaskToSucceed(context.TODO(), tagTask)
+		err = d.waitForPVETaskToSucceed(ctx, tagTask)
 	}
 
-	if err != nil {
+	if err != nil && !proxmox.IsErrNoop(err) {
 		return fmt.Errorf("failed to add tag '%s' to Proxmox VE virtual machine ID='%d': %w", pveMachineTag, *d.PVEMachineID, err)
 	}
 
-	log.Info("Configuring machine hardware...")
-
-	if err := d.setupHardware(context.TODO()); err != nil {
-		return err
-	}
-
-	log.Info("Configuring cloud-init...")
-
-	if err := d.setupCloudinit(context.TODO()); err != nil {
-		return err
+	if antiAffinityTag := d.getAntiAffinityTag(); antiAffinityTag != "" {
+		err := d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+			return vm.AddTag(ctx, antiAffinityTag)
+		})
+		if err != nil && !proxmox.IsErrNoop(err) {
+			return fmt.Errorf("failed to add tag '%s' to Proxmox VE virtual machine ID='%d': %w", antiAffinityTag, *d.PVEMachineID, err)
+		}
 	}
 
-	log.Info("Starting the machine...")
+	return d.allocateIPAddress(ctx)
+}
 
-	if err := d.Start(); err != nil {
+// Starts the current machine for its initialization.
+func (d *Driver) startForInitialization(ctx context.Context) error {
+	if err := d.start(ctx); err != nil {
 		return fmt.Errorf("failed to start the machine: %w", err)
 	}
 
-	log.Info("Waiting for cloud-init to finish...")
-
-	if err := d.waitForCloudinit(); err != nil {
-		return fmt.Errorf("failed waiting for cloud-init to finish: %w", err)
-	}
-
-	log.Info("Cleaning up...")
-
-	if err := d.cleanupCloudinit(context.TODO()); err != nil {
-		return err
-	}
-
 	return nil
 }
 
 // GetState implements drivers.Driver.
 func (d *Driver) GetState() (state.State, error) {
-	machine, err := d.getCurrentMachine(context.TODO())
+	ctx := d.getContext()
+
+	machine, err := d.getCurrentMachine(ctx)
 	if err != nil {
 		return state.Error, err
 	}
 
-	if !machine.IsRunning() {
-		return state.Stopped, nil
+	machineState, err := getMachineState(machine)
+	if machineState != state.Running {
+		return machineState, err
 	}
 
-	_, err = machine.AgentOsInfo(context.TODO())
+	_, err = machine.AgentOsInfo(ctx)
 	if err == nil {
 		return state.Running, nil
 	}
@@ -222,7 +405,14 @@ func (d *Driver) GetState() (state.State, error) {
 
 // GetIP implements drivers.Driver.
 func (d *Driver) GetIP() (string, error) {
-	machine, err := d.getCurrentMachine(context.TODO())
+	// Static IP address is known without asking the guest agent
+	if d.IPAddress != "" {
+		return d.IPAddress, nil
+	}
+
+	ctx := d.getContext()
+
+	machine, err := d.getCurrentMachine(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -248,7 +438,7 @@ func (d *Driver) GetIP() (string, error) {
 
 	networkInterfaceMAC = strings.ToLower(networkInterfaceMAC)
 
-	osNetworkInterfaces, err := machine.AgentGetNetworkIFaces(context.TODO())
+	osNetworkInterfaces, err := machine.AgentGetNetworkIFaces(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to retrieve Proxmox VE machine's ID='%d' network interfaces: %w", d.PVEMachineID, err)
 	}
@@ -315,8 +505,48 @@ func (d *Driver) DriverName() string {
 }
 
 // Start implements drivers.Driver.
+// Suspended machine is resumed instead, and creation of the machine interrupted by death of the process is finished.
 func (d *Driver) Start() error {
-	err := d.runTaskOnCurrentMachine(context.TODO(), func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+	ctx := d.getContext()
+
+	resume, err := d.loadCreateState()
+	if err != nil {
+		return err
+	}
+
+	if resume {
+		log.Infof("Finishing creation of the machine ID='%d' after phase '%s'...", *d.PVEMachineID, d.CreatePhase)
+
+		if err := d.initialize(ctx); err != nil {
+			return fmt.Errorf("failed to finish creation of the machine: %w", err)
+		}
+
+		return nil
+	}
+
+	return d.start(ctx)
+}
+
+// Starts the current machine, or resumes it if it is suspended.
+func (d *Driver) start(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, durationOrDefault(d.StartTimeout, pveTaskPollingTimeout))
+	defer cancel()
+
+	machine, err := d.getCurrentMachine(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start the machine: %w", err)
+	}
+
+	switch machineState, _ := getMachineState(machine); machineState {
+	case state.Paused:
+		return d.Resume(ctx)
+	case state.Running:
+		log.Info("Machine is already running")
+		return nil
+	}
+
+	// Machine suspended to disk is resumed by Proxmox VE on start
+	err = d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
 		return vm.Start(ctx)
 	})
 	if err != nil {
@@ -328,7 +558,7 @@ func (d *Driver) Start() error {
 
 // Restart implements drivers.Driver.
 func (d *Driver) Restart() error {
-	err := d.runTaskOnCurrentMachine(context.TODO(), func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+	err := d.runTaskOnCurrentMachine(d.getContext(), func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
 		return vm.Reboot(ctx)
 	})
 	if err != nil {
@@ -340,10 +570,7 @@ func (d *Driver) Restart() error {
 
 // Stop implements drivers.Driver.
 func (d *Driver) Stop() error {
-	err := d.runTaskOnCurrentMachine(context.TODO(), func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
-		return vm.Shutdown(ctx)
-	})
-	if err != nil {
+	if err := d.shutdown(d.getContext()); err != nil {
 		return fmt.Errorf("failed to stop the machine: %w", err)
 	}
 
@@ -352,7 +579,12 @@ func (d *Driver) Stop() error {
 
 // Kill implements drivers.Driver.
 func (d *Driver) Kill() error {
-	err := d.runTaskOnCurrentMachine(context.TODO(), func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+	return d.kill(d.getContext())
+}
+
+// Stops the current machine immediately.
+func (d *Driver) kill(ctx context.Context) error {
+	err := d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
 		return vm.Stop(ctx)
 	})
 	if err != nil {
@@ -363,18 +595,73 @@ func (d *Driver) Kill() error {
 }
 
 // Remove implements drivers.Driver.
+// Machine in any power state is removed, and machine that no longer exists is treated as already removed.
+// Protected machine is removed only if the removal is forced via environment variable.
 func (d *Driver) Remove() error {
-	err := d.Kill()
-	if err != nil {
+	force, _ := strconv.ParseBool(os.Getenv(envForceRemove))
+
+	// Machine whose creation was interrupted may be known only from the persisted state
+	if _, err := d.loadCreateState(); err != nil {
 		return err
 	}
 
-	err = d.runTaskOnCurrentMachine(context.TODO(), func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
-		return vm.Delete(ctx)
-	})
+	return d.remove(d.getContext(), force)
+}
+
+// Removes the current machine. Protection of the machine is disabled first if force is set.
+func (d *Driver) remove(ctx context.Context, force bool) error {
+	if d.PVEMachineID == nil {
+		log.Info("Machine was not created, nothing to remove")
+		return nil
+	}
+
+	machine, err := d.getCurrentMachine(ctx)
+	if errors.Is(err, ErrPVEVirtualMachineNotFound) {
+		log.Warnf("Machine ID='%d' no longer exists, assuming it was already removed", *d.PVEMachineID)
+		return d.deleteCreateState()
+	}
+
 	if err != nil {
 		return fmt.Errorf("failed to remove the machine: %w", err)
 	}
 
-	return nil
+	if machine.VirtualMachineConfig.Protection == 1 {
+		if !force {
+			return fmt.Errorf("failed to remove the machine ID='%d': %w, set environment variable '%s=true' to remove it anyway", *d.PVEMachineID, ErrMachineProtected, envForceRemove)
+		}
+
+		if err := d.disableProtection(ctx); err != nil {
+			return fmt.Errorf("failed to remove the machine: %w", err)
+		}
+	}
+
+	// HA manager would otherwise start the machine again or intercept stop request
+	if err := d.removePVEVirtualMachineFromHA(ctx, machine); err != nil {
+		return fmt.Errorf("failed to remove the machine: %w", err)
+	}
+
+	// Machine suspended to disk is stopped but locked, so it is resumed first and stopped like a running one
+	suspendedToDisk := machine.IsStopped() && machine.Lock == pveLockSuspended
+
+	if suspendedToDisk {
+		if err := d.start(ctx); err != nil {
+			return fmt.Errorf("failed to remove the machine: %w", err)
+		}
+	}
+
+	if !machine.IsStopped() || suspendedToDisk {
+		if err := d.kill(ctx); err != nil {
+			return fmt.Errorf("failed to remove the machine: %w", err)
+		}
+	}
+
+	// Extra disks are owned by the machine, so they are deleted together with it
+	deleteCtx, cancel := context.WithTimeout(ctx, durationOrDefault(d.DeleteTimeout, pveTaskPollingTimeout))
+	defer cancel()
+
+	if err := d.deletePVEVirtualMachine(deleteCtx, machine); err != nil {
+		return fmt.Errorf("failed to remove the machine: %w", err)
+	}
+
+	return d.deleteCreateState()
 }
@@ -0,0 +1,158 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/rancher/machine/libmachine/drivers"
+	"github.com/rancher/machine/libmachine/mcnflag"
+)
+
+// Flags configuring connection to Proxmox VE and the resource pool.
+var connectionFlagNames = []string{
+	flagURL,
+	flagInsecureTLS,
+	flagTokenID,
+	flagTokenSecret,
+	flagResourcePool,
+}
+
+// OrphanedMachine is a machine created by the driver in the resource pool, that is missing in the machine store.
+type OrphanedMachine struct {
+	// Proxmox VE ID of the machine.
+	VMID int
+
+	// Name of the machine.
+	Name string
+
+	// Node the machine is on.
+	Node string
+
+	// Proxmox VE status of the machine (e.g. 'running').
+	Status string
+
+	// Creation time of the machine, zero if unknown.
+	Created time.Time
+}
+
+// ConnectionFlags returns flags configuring connection to Proxmox VE and the resource pool.
+func ConnectionFlags() []mcnflag.Flag {
+	return slices.DeleteFunc((&Driver{}).GetCreateFlags(), func(flag mcnflag.Flag) bool {
+		return !slices.Contains(connectionFlagNames, flag.String())
+	})
+}
+
+// NewPoolDriver creates a driver operating on machines in the resource pool rather than on a single machine.
+// Only connection flags are used from given options.
+func NewPoolDriver(opts drivers.DriverOptions) (*Driver, error) {
+	d := NewDriver("", "")
+
+	if err := d.setConnectionFromFlags(opts); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// ListOrphanedMachines returns machines created by the driver in the resource pool, whose name nor ID is known.
+func (d *Driver) ListOrphanedMachines(ctx context.Context, knownNames map[string]bool, knownIDs map[int]bool) ([]*OrphanedMachine, error) {
+	cluster, err := d.getPVEClient().Cluster(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve Proxmox VE cluster: %w", err)
+	}
+
+	resources, err := cluster.Resources(ctx, "vm")
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve Proxmox VE cluster resources: %w", err)
+	}
+
+	orphans := []*OrphanedMachine{}
+
+	for _, resource := range getOrphanCandidates(resources, d.ResourcePoolName, knownNames, knownIDs) {
+		vmid := int(resource.VMID)
+
+		// Creation time and description are recorded only in the configuration of the machine
+		machine, err := d.getPVEVirtualMachineOnNode(ctx, vmid, resource.Node)
+		if err != nil {
+			return nil, err
+		}
+
+		// Machine whose creation died during the clone is not tagged yet, only marked by its description
+		if !isPVEMachineCreatedByDriver(machine.VirtualMachineConfig.Tags, machine.VirtualMachineConfig.Description) {
+			continue
+		}
+
+		orphans = append(orphans, &OrphanedMachine{
+			VMID:    vmid,
+			Name:    resource.Name,
+			Node:    resource.Node,
+			Status:  resource.Status,
+			Created: parsePVECreationTime(machine.VirtualMachineConfig.Meta),
+		})
+	}
+
+	slices.SortFunc(orphans, func(a, b *OrphanedMachine) int {
+		return a.VMID - b.VMID
+	})
+
+	return orphans, nil
+}
+
+// Returns machines in a given resource pool whose name nor ID is known, possibly created by the driver.
+// Untagged machines are included too, since a machine is tagged only after it is cloned.
+func getOrphanCandidates(resources proxmox.ClusterResources, poolName string, knownNames map[string]bool, knownIDs map[int]bool) proxmox.ClusterResources {
+	candidates := proxmox.ClusterResources{}
+
+	for _, resource := range resources {
+		if resource.Type != "qemu" || resource.Pool != poolName || resource.Template != 0 || resource.VMID > math.MaxInt {
+			continue
+		}
+
+		if knownNames[resource.Name] || knownIDs[int(resource.VMID)] {
+			continue
+		}
+
+		candidates = append(candidates, resource)
+	}
+
+	return candidates
+}
+
+// RemoveOrphanedMachine stops and deletes an orphaned machine.
+// Protected machine is not removed.
+func (d *Driver) RemoveOrphanedMachine(ctx context.Context, orphan *OrphanedMachine) error {
+	vmid := orphan.VMID
+
+	// Orphan has no machine store, so its driver differs from the pool driver only in the machine
+	orphanDriver := &Driver{
+		BaseDriver:   &drivers.BaseDriver{MachineName: orphan.Name},
+		config:       d.config,
+		pveClient:    d.getPVEClient(),
+		PVEMachineID: &vmid,
+	}
+
+	return orphanDriver.remove(ctx, false)
+}
+
+// Parses creation time from Proxmox VE 'meta' option (e.g. 'creation-qemu=8.1.2,ctime=1700000000').
+// Returns zero time if the creation time is not recorded.
+func parsePVECreationTime(meta string) time.Time {
+	for _, param := range strings.Split(meta, ",") {
+		value, found := strings.CutPrefix(strings.TrimSpace(param), "ctime=")
+		if !found {
+			continue
+		}
+
+		if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Unix(seconds, 0)
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,55 @@
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ConnectionFlags(t *testing.T) {
+	names := []string{}
+	for _, flag := range ConnectionFlags() {
+		names = append(names, flag.String())
+	}
+
+	require.ElementsMatch(t, connectionFlagNames, names)
+}
+
+func Test_parsePVECreationTime(t *testing.T) {
+	require.Equal(t, time.Unix(1700000000, 0), parsePVECreationTime("creation-qemu=8.1.2,ctime=1700000000"))
+
+	for _, meta := range []string{"", "creation-qemu=8.1.2", "ctime=soon"} {
+		t.Run(meta, func(t *testing.T) {
+			require.True(t, parsePVECreationTime(meta).IsZero())
+		})
+	}
+}
+
+func Test_getOrphanCandidates(t *testing.T) {
+	resources := proxmox.ClusterResources{
+		{Type: "qemu", VMID: 5000, Name: "tagged", Pool: "rancher", Tags: pveMachineTag},
+		{Type: "qemu", VMID: 5001, Name: "untagged", Pool: "rancher"},
+		{Type: "qemu", VMID: 5002, Name: "known-name", Pool: "rancher", Tags: pveMachineTag},
+		{Type: "qemu", VMID: 5003, Name: "known-id", Pool: "rancher", Tags: pveMachineTag},
+		{Type: "qemu", VMID: 5004, Name: "other-pool", Pool: "other", Tags: pveMachineTag},
+		{Type: "qemu", VMID: 100, Name: "template", Pool: "rancher", Template: 1},
+		{Type: "lxc", VMID: 200, Name: "container", Pool: "rancher"},
+	}
+
+	names := []string{}
+	for _, resource := range getOrphanCandidates(resources, "rancher", map[string]bool{"known-name": true}, map[int]bool{5003: true}) {
+		names = append(names, resource.Name)
+	}
+
+	require.Equal(t, []string{"tagged", "untagged"}, names)
+}
+
+func Test_isPVEMachineCreatedByDriver(t *testing.T) {
+	require.True(t, isPVEMachineCreatedByDriver("docker-machine;anti-affinity-workers", ""))
+	require.True(t, isPVEMachineCreatedByDriver("", getPVEMachineDescription("")))
+	require.True(t, isPVEMachineCreatedByDriver("", getPVEMachineDescription("Ubuntu Server 24.04")))
+	require.False(t, isPVEMachineCreatedByDriver("", "Ubuntu Server 24.04"))
+	require.False(t, isPVEMachineCreatedByDriver("ubuntu", "Notes: "+pveMachineDescriptionMarker))
+}
@@ -39,16 +39,25 @@ func (d *Driver) setupHardware(ctx context.Context) error {
 		})
 	}
 
-	if len(options) < 1 {
-		return nil
+	if d.Protection {
+		options = append(options, proxmox.VirtualMachineOption{
+			Name:  "protection",
+			Value: 1,
+		})
+	}
+
+	if len(options) > 0 {
+		err := d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+			return vm.Config(ctx, options...)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to configure hardware: %w", err)
+		}
 	}
 
-	err := d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
-		return vm.Config(ctx, options...)
-	})
-	if err != nil {
-		return fmt.Errorf("failed to configure hardware: %w", err)
+	if err := d.setupDisk(ctx); err != nil {
+		return err
 	}
 
-	return nil
+	return d.setupExtraDisks(ctx)
 }
@@ -0,0 +1,177 @@
+package driver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Available provisioners of the machine's operating system.
+const (
+	provisionerCloudinit = "cloud-init"
+	provisionerIgnition  = "ignition"
+)
+
+// Available provisioners of the machine's operating system.
+var provisioners = []string{
+	provisionerCloudinit,
+	provisionerIgnition,
+}
+
+// Version of Ignition specification of generated configs, supported by Flatcar 3185+ and Fedora CoreOS 35+.
+const ignitionSpecVersion = "3.3.0"
+
+// Loads Ignition config fragment from a file, or uses the value as inline fragment if no such file exists.
+// Returns the fragment as Ignition JSON.
+func loadIgnitionConfig(value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", nil
+	}
+
+	if fileInfo, err := os.Stat(value); err == nil && !fileInfo.IsDir() {
+		content, err := os.ReadFile(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file '%s': %w", value, err)
+		}
+
+		value = string(content)
+	}
+
+	return parseIgnitionConfig(value)
+}
+
+// Parses Ignition config (JSON) fragment.
+// Butane configs are not translated, since keys unknown to a translator would produce invalid Ignition.
+func parseIgnitionConfig(content string) (string, error) {
+	config := map[string]interface{}{}
+
+	if err := json.Unmarshal([]byte(content), &config); err != nil {
+		return "", fmt.Errorf("config is not a valid Ignition JSON, translate Butane configs with butane first: %w", err)
+	}
+
+	ignition, _ := config["ignition"].(map[string]interface{})
+	if version, _ := ignition["version"].(string); !strings.HasPrefix(version, "3.") {
+		return "", fmt.Errorf("version '%s' of Ignition config is not supported, use version 3.x", version)
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal Ignition config: %w", err)
+	}
+
+	return string(configJSON), nil
+}
+
+// Generates Ignition config for the current machine.
+func (d *Driver) generateIgnitionConfig() (string, error) {
+	sshPublicKey, err := os.ReadFile(d.GetSSHPublicKeyPath())
+	if err != nil {
+		return "", fmt.Errorf("failed to read machine's SSH public key: %w", err)
+	}
+
+	ignition := map[string]interface{}{
+		"version": ignitionSpecVersion,
+	}
+
+	// Ignition merges the user-supplied fragment itself, following its own merge rules
+	if d.IgnitionConfig != "" {
+		ignition["config"] = map[string]interface{}{
+			"merge": []map[string]interface{}{
+				{
+					"source": getDataURL(d.IgnitionConfig),
+				},
+			},
+		}
+	}
+
+	config := map[string]interface{}{
+		"ignition": ignition,
+		"passwd": map[string]interface{}{
+			"users": []map[string]interface{}{
+				{
+					"name": d.SSHUser,
+					"sshAuthorizedKeys": []string{
+						strings.TrimSpace(string(sshPublicKey)),
+					},
+				},
+			},
+		},
+		"storage": map[string]interface{}{
+			"files": []map[string]interface{}{
+				{
+					"path":      "/etc/hostname",
+					"mode":      0o644, //nolint:mnd
+					"overwrite": true,
+					"contents": map[string]interface{}{
+						"source": getDataURL(d.MachineName + "\n"),
+					},
+				},
+				{
+					"path":      "/etc/sudoers.d/" + d.SSHUser,
+					"mode":      0o440, //nolint:mnd
+					"overwrite": true,
+					"contents": map[string]interface{}{
+						"source": getDataURL(d.SSHUser + " ALL=(ALL) NOPASSWD:ALL\n"),
+					},
+				},
+			},
+		},
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal Ignition config: %w", err)
+	}
+
+	return string(configJSON), nil
+}
+
+// Passes Ignition config to the current machine as user-data of the cloud-init ISO,
+// which Ignition reads on the Proxmox VE platform.
+func (d *Driver) setupIgnition(ctx context.Context) error {
+	machine, err := d.getCurrentMachine(ctx)
+	if err != nil {
+		return err
+	}
+
+	config, err := d.generateIgnitionConfig()
+	if err != nil {
+		return fmt.Errorf("failed to generate Ignition config: %w", err)
+	}
+
+	// ISO left behind by an interrupted creation of the machine is replaced
+	if err := machine.UnmountCloudInitISO(ctx, d.ISODeviceName); err != nil {
+		return fmt.Errorf("failed to remove Ignition ISO: %w", err)
+	}
+
+	// Ignition reads only user-data, metadata is kept for the ISO to stay a valid NoCloud data source
+	if err := machine.CloudInit(ctx, d.ISODeviceName, config, "instance-id: "+d.MachineName+"\n", "", ""); err != nil {
+		return fmt.Errorf("failed to pass Ignition config to Proxmox VE virtual machine ID='%d': %w", machine.VMID, err)
+	}
+
+	return nil
+}
+
+// Blocks until Ignition and the first boot finish on the current machine.
+func (d *Driver) waitForIgnition(ctx context.Context) error {
+	// SSH user is created by Ignition, so the result is already recorded once the command can run
+	err := d.waitForCommandOnCurrentMachine(
+		ctx,
+		"sudo test -e /etc/.ignition-result.json && sudo systemctl is-system-running --wait",
+		durationOrDefault(d.CloudinitTimeout, pveTaskPollingTimeout),
+	)
+	if errors.Is(err, ErrNonZeroExitCode) {
+		return fmt.Errorf("first boot did not finish successfully: %w", err)
+	}
+
+	return err
+}
+
+// Returns data URL with base64 encoded content.
+func getDataURL(content string) string {
+	return "data:;base64," + base64.StdEncoding.EncodeToString([]byte(content))
+}
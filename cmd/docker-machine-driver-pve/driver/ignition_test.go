@@ -0,0 +1,56 @@
+package driver
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseIgnitionConfig(t *testing.T) {
+	config, err := parseIgnitionConfig(`{"ignition": {"version": "3.4.0"}, "systemd": {"units": [{"name": "a.service", "enabled": true}]}}`)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"ignition":{"version":"3.4.0"},"systemd":{"units":[{"enabled":true,"name":"a.service"}]}}`, config)
+
+	for _, value := range []string{
+		`{"ignition": {"version": "2.3.0"}}`,
+		`{"ignition": `,
+		"packages: [htop]",
+		"variant: fcos\nversion: 1.5.0\npasswd:\n  users:\n    - name: backup\n",
+	} {
+		t.Run(value, func(t *testing.T) {
+			_, err := parseIgnitionConfig(value)
+			require.Error(t, err)
+		})
+	}
+}
+
+func Test_generateIgnitionConfig(t *testing.T) {
+	d := NewDriver("machine", t.TempDir())
+	d.SSHUser = "service"
+	d.IgnitionConfig = `{"ignition":{"version":"3.3.0"}}`
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(d.GetSSHPublicKeyPath()), 0o700))
+	require.NoError(t, os.WriteFile(d.GetSSHPublicKeyPath(), []byte("ssh-ed25519 AAAA\n"), 0o600))
+
+	config, err := d.generateIgnitionConfig()
+	require.NoError(t, err)
+
+	parsedConfig := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(config), &parsedConfig))
+	require.Equal(t, map[string]interface{}{
+		"version": "3.3.0",
+		"config": map[string]interface{}{
+			"merge": []interface{}{
+				map[string]interface{}{"source": "data:;base64,eyJpZ25pdGlvbiI6eyJ2ZXJzaW9uIjoiMy4zLjAifX0="},
+			},
+		},
+	}, parsedConfig["ignition"])
+	require.Equal(t, map[string]interface{}{
+		"users": []interface{}{
+			map[string]interface{}{"name": "service", "sshAuthorizedKeys": []interface{}{"ssh-ed25519 AAAA"}},
+		},
+	}, parsedConfig["passwd"])
+}
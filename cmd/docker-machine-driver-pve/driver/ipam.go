@@ -0,0 +1,288 @@
+package driver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/rancher/machine/libmachine/log"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Prefix of the tag recording static IP address allocated to a machine.
+const pveIPAddressTagPrefix = "docker-machine-ip-"
+
+// Maximum number of attempts to allocate an address not claimed by a concurrently created machine.
+const ipAllocationAttempts = 5
+
+// Pool of static IPv4 addresses.
+type ipPool struct {
+	// First address of the pool.
+	Start netip.Addr
+
+	// Last address of the pool.
+	End netip.Addr
+
+	// Prefix length of the network the addresses belong to.
+	Bits int
+}
+
+// Parses pool of static IPv4 addresses (e.g. '10.20.0.10-10.20.0.50/24' or '10.20.0.0/24').
+func parseIPPool(value string) (*ipPool, error) {
+	addresses, bitsValue, found := strings.Cut(strings.TrimSpace(value), "/")
+	if !found {
+		return nil, errors.New("pool must include prefix length (e.g. '10.20.0.10-10.20.0.50/24')")
+	}
+
+	prefix, err := netip.ParsePrefix("0.0.0.0/" + bitsValue)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prefix length '%s'", bitsValue)
+	}
+
+	startValue, endValue, isRange := strings.Cut(addresses, "-")
+
+	start, err := netip.ParseAddr(strings.TrimSpace(startValue))
+	if err != nil || !start.Is4() {
+		return nil, fmt.Errorf("invalid IPv4 address '%s'", startValue)
+	}
+
+	pool := &ipPool{Start: start, End: start, Bits: prefix.Bits()}
+
+	if isRange {
+		if pool.End, err = netip.ParseAddr(strings.TrimSpace(endValue)); err != nil || !pool.End.Is4() {
+			return nil, fmt.Errorf("invalid IPv4 address '%s'", endValue)
+		}
+	} else {
+		network := netip.PrefixFrom(start, pool.Bits).Masked()
+		pool.Start, pool.End = network.Addr(), getBroadcastAddress(network)
+
+		// Whole network without network and broadcast addresses, point-to-point networks have none
+		//nolint:mnd
+		if pool.Bits < 31 {
+			pool.Start, pool.End = pool.Start.Next(), pool.End.Prev()
+		}
+	}
+
+	if !pool.Start.IsValid() || !pool.End.IsValid() || pool.End.Less(pool.Start) {
+		return nil, errors.New("pool contains no addresses")
+	}
+
+	network := netip.PrefixFrom(pool.Start, pool.Bits).Masked()
+	if !network.Contains(pool.End) {
+		return nil, fmt.Errorf("addresses '%s' and '%s' are not in the same /%d network", pool.Start, pool.End, pool.Bits)
+	}
+
+	return pool, nil
+}
+
+// Returns the lowest address of the pool that is not used.
+func (pool *ipPool) findFreeAddress(usedAddresses map[netip.Addr]bool) (netip.Addr, bool) {
+	for address := pool.Start; address.IsValid() && !pool.End.Less(address); address = address.Next() {
+		if !usedAddresses[address] {
+			return address, true
+		}
+	}
+
+	return netip.Addr{}, false
+}
+
+// Checks whether an address belongs to the pool.
+func (pool *ipPool) contains(address netip.Addr) bool {
+	return !address.Less(pool.Start) && !pool.End.Less(address)
+}
+
+// Returns the last address of an IPv4 network.
+func getBroadcastAddress(network netip.Prefix) netip.Addr {
+	address := network.Addr().As4()
+	binary.BigEndian.PutUint32(address[:], binary.BigEndian.Uint32(address[:])|math.MaxUint32>>network.Bits())
+
+	return netip.AddrFrom4(address)
+}
+
+// Allocates a static IP address for the current machine and records it on the machine as a tag.
+func (d *Driver) allocateIPAddress(ctx context.Context) error {
+	if d.IPPool == nil || d.IPAddress != "" {
+		return nil
+	}
+
+	excludedAddresses := map[netip.Addr]bool{}
+
+	for range ipAllocationAttempts {
+		usedAddresses, err := d.getUsedIPAddresses(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Address recorded by an interrupted creation of the machine is kept, unless another machine claimed it first
+		for address, vmids := range usedAddresses {
+			if slices.Contains(vmids, *d.PVEMachineID) && !hasLowerVMID(vmids, *d.PVEMachineID) && d.IPPool.contains(address) {
+				d.IPAddress = address.String()
+
+				log.Infof("Reusing static IP address %s/%d", d.IPAddress, d.IPPool.Bits)
+
+				return nil
+			}
+		}
+
+		unavailableAddresses := maps.Clone(excludedAddresses)
+
+		for address := range usedAddresses {
+			unavailableAddresses[address] = true
+		}
+
+		if gateway, err := netip.ParseAddr(d.IPGateway); err == nil {
+			unavailableAddresses[gateway] = true
+		}
+
+		address, found := d.IPPool.findFreeAddress(unavailableAddresses)
+		if !found {
+			return fmt.Errorf("no free address left in pool %s-%s", d.IPPool.Start, d.IPPool.End)
+		}
+
+		tag := pveIPAddressTagPrefix + address.String()
+
+		err = d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+			return vm.AddTag(ctx, tag)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add tag '%s' to Proxmox VE virtual machine ID='%d': %w", tag, *d.PVEMachineID, err)
+		}
+
+		// Machine created concurrently may have claimed the same address, the one with lower ID keeps it
+		usedAddresses, err = d.getUsedIPAddresses(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !hasLowerVMID(usedAddresses[address], *d.PVEMachineID) {
+			d.IPAddress = address.String()
+
+			log.Infof("Allocated static IP address %s/%d", d.IPAddress, d.IPPool.Bits)
+
+			return nil
+		}
+
+		log.Warnf("Address %s was claimed by another machine, will retry...", address)
+
+		err = d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+			return vm.RemoveTag(ctx, tag)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to remove tag '%s' from Proxmox VE virtual machine ID='%d': %w", tag, *d.PVEMachineID, err)
+		}
+
+		excludedAddresses[address] = true
+	}
+
+	return fmt.Errorf("failed to allocate static IP address after %d attempts", ipAllocationAttempts)
+}
+
+// Returns addresses recorded on machines managed by the driver in the resource pool, with IDs of the machines.
+func (d *Driver) getUsedIPAddresses(ctx context.Context) (map[netip.Addr][]int, error) {
+	cluster, err := d.getPVEClient().Cluster(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve Proxmox VE cluster: %w", err)
+	}
+
+	resources, err := cluster.Resources(ctx, "vm")
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve Proxmox VE cluster resources: %w", err)
+	}
+
+	usedAddresses := map[netip.Addr][]int{}
+
+	for _, resource := range resources {
+		if resource.Type != "qemu" || resource.Pool != d.ResourcePoolName || resource.VMID > math.MaxInt {
+			continue
+		}
+
+		if !hasPVETag(resource.Tags, pveMachineTag) {
+			continue
+		}
+
+		for _, tag := range splitPVETags(resource.Tags) {
+			addressValue, found := strings.CutPrefix(tag, pveIPAddressTagPrefix)
+			if !found {
+				continue
+			}
+
+			if address, err := netip.ParseAddr(addressValue); err == nil {
+				usedAddresses[address] = append(usedAddresses[address], int(resource.VMID))
+			}
+		}
+	}
+
+	return usedAddresses, nil
+}
+
+// Checks whether any of IDs is lower than a given ID.
+func hasLowerVMID(vmids []int, vmid int) bool {
+	for _, otherVMID := range vmids {
+		if otherVMID < vmid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Generates cloud-init network-config for the current machine.
+// Returns empty string if no static IP address is assigned.
+func (d *Driver) generateCloudinitNetworkConfig(macAddress string) (string, error) {
+	if d.IPAddress == "" {
+		return "", nil
+	}
+
+	ethernet := map[string]interface{}{
+		"match": map[string]interface{}{
+			"macaddress": strings.ToLower(macAddress),
+		},
+		"addresses": []string{
+			fmt.Sprintf("%s/%d", d.IPAddress, d.IPPool.Bits),
+		},
+	}
+
+	if d.IPGateway != "" {
+		ethernet["routes"] = []map[string]interface{}{
+			{
+				"to":  "default",
+				"via": d.IPGateway,
+			},
+		}
+	}
+
+	if len(d.IPNameservers) > 0 || len(d.IPSearchDomains) > 0 {
+		nameservers := map[string]interface{}{}
+
+		if len(d.IPNameservers) > 0 {
+			nameservers["addresses"] = d.IPNameservers
+		}
+
+		if len(d.IPSearchDomains) > 0 {
+			nameservers["search"] = d.IPSearchDomains
+		}
+
+		ethernet["nameservers"] = nameservers
+	}
+
+	networkConfig := map[string]interface{}{
+		"version": 2, //nolint:mnd
+		"ethernets": map[string]interface{}{
+			d.NetworkInterfaceName: ethernet,
+		},
+	}
+
+	networkConfigYAML, err := yaml.Marshal(&networkConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cloud-init network-config: %w", err)
+	}
+
+	return string(networkConfigYAML), nil
+}
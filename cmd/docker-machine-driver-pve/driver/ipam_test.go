@@ -0,0 +1,84 @@
+package driver
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseIPPool(t *testing.T) {
+	tests := map[string]ipPool{
+		"10.20.0.10-10.20.0.50/24": {Start: netip.MustParseAddr("10.20.0.10"), End: netip.MustParseAddr("10.20.0.50"), Bits: 24},
+		"10.20.0.0/24":             {Start: netip.MustParseAddr("10.20.0.1"), End: netip.MustParseAddr("10.20.0.254"), Bits: 24},
+		"10.20.0.7/29":             {Start: netip.MustParseAddr("10.20.0.1"), End: netip.MustParseAddr("10.20.0.6"), Bits: 29},
+		"10.20.0.10/32":            {Start: netip.MustParseAddr("10.20.0.10"), End: netip.MustParseAddr("10.20.0.10"), Bits: 32},
+	}
+
+	for value, expectedPool := range tests {
+		t.Run(value, func(t *testing.T) {
+			pool, err := parseIPPool(value)
+			require.NoError(t, err)
+			require.Equal(t, expectedPool, *pool)
+		})
+	}
+
+	for _, value := range []string{"", "10.20.0.10-10.20.0.50", "10.20.0.50-10.20.0.10/24", "10.20.0.10-10.20.1.10/24", "fd00::1-fd00::5/64", "10.20.0.10/33"} {
+		t.Run(value, func(t *testing.T) {
+			_, err := parseIPPool(value)
+			require.Error(t, err)
+		})
+	}
+}
+
+func Test_ipPool_findFreeAddress(t *testing.T) {
+	pool, err := parseIPPool("10.20.0.10-10.20.0.12/24")
+	require.NoError(t, err)
+
+	address, found := pool.findFreeAddress(map[netip.Addr]bool{
+		netip.MustParseAddr("10.20.0.10"): true,
+	})
+	require.True(t, found)
+	require.Equal(t, "10.20.0.11", address.String())
+
+	_, found = pool.findFreeAddress(map[netip.Addr]bool{
+		netip.MustParseAddr("10.20.0.10"): true,
+		netip.MustParseAddr("10.20.0.11"): true,
+		netip.MustParseAddr("10.20.0.12"): true,
+	})
+	require.False(t, found)
+
+	require.True(t, pool.contains(netip.MustParseAddr("10.20.0.12")))
+	require.False(t, pool.contains(netip.MustParseAddr("10.20.0.13")))
+}
+
+func Test_generateCloudinitNetworkConfig(t *testing.T) {
+	pool, err := parseIPPool("10.20.0.10-10.20.0.50/24")
+	require.NoError(t, err)
+
+	d := NewDriver("machine", "")
+	d.NetworkInterfaceName = "net0"
+	d.IPPool = pool
+	d.IPAddress = "10.20.0.10"
+	d.IPGateway = "10.20.0.1"
+	d.IPNameservers = []string{"10.20.0.2", "10.20.0.3"}
+
+	networkConfig, err := d.generateCloudinitNetworkConfig("BC:24:11:45:CD:E8")
+	require.NoError(t, err)
+	require.YAMLEq(t, `
+version: 2
+ethernets:
+  net0:
+    match:
+      macaddress: bc:24:11:45:cd:e8
+    addresses:
+      - 10.20.0.10/24
+    routes:
+      - to: default
+        via: 10.20.0.1
+    nameservers:
+      addresses:
+        - 10.20.0.2
+        - 10.20.0.3
+`, networkConfig)
+}
@@ -5,9 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/luthermonson/go-proxmox"
+	"github.com/rancher/machine/libmachine/log"
 	machine_ssh "github.com/rancher/machine/libmachine/ssh"
 	"golang.org/x/crypto/ssh"
 )
@@ -17,10 +21,18 @@ type TaskCallback = func(context.Context, *proxmox.VirtualMachine) (*proxmox.Tas
 const (
 	// Tag for machines managed by the driver.
 	pveMachineTag = "docker-machine"
+
+	// Line of the description of machines created by the driver, set by the clone itself since the machine is tagged only later.
+	pveMachineDescriptionMarker = "Created by Docker Machine driver for Proxmox VE."
+
+	// Timeout of establishing SSH connection to a machine, including the handshake.
+	sshConnectTimeout = 30 * time.Second
 )
 
 var ErrNonZeroExitCode = errors.New("command finished with non-zero exit code")
 
+var ErrMachineProtected = errors.New("machine is protected")
+
 // Returns the current machine.
 func (d *Driver) getCurrentMachine(ctx context.Context) (*proxmox.VirtualMachine, error) {
 	if d.PVEMachineID == nil {
@@ -32,13 +44,45 @@ func (d *Driver) getCurrentMachine(ctx context.Context) (*proxmox.VirtualMachine
 		return nil, fmt.Errorf("failed to retrieve current Proxmox VE virtual machine ID='%d': %w", *d.PVEMachineID, err)
 	}
 
-	if !vm.HasTag(pveMachineTag) {
+	// Machine that failed before it was tagged is recognized by its description, so it can be removed
+	if !isPVEMachineCreatedByDriver(vm.VirtualMachineConfig.Tags, vm.VirtualMachineConfig.Description) {
 		return nil, fmt.Errorf("current Proxmox VE virtual machine ID='%d' does not have expected tag '%s', it could have been replaced or modified outside the driver", *d.PVEMachineID, pveMachineTag)
 	}
 
 	return vm, nil
 }
 
+// Returns whether a Proxmox VE virtual machine with given tags and description was created by the driver.
+func isPVEMachineCreatedByDriver(tags, description string) bool {
+	return hasPVETag(tags, pveMachineTag) || slices.Contains(strings.Split(description, "\n"), pveMachineDescriptionMarker)
+}
+
+// Returns description of a machine cloned from a template with a given description.
+func getPVEMachineDescription(templateDescription string) string {
+	if strings.TrimSpace(templateDescription) == "" {
+		return pveMachineDescriptionMarker
+	}
+
+	return pveMachineDescriptionMarker + "\n\n" + templateDescription
+}
+
+// Disables protection of the current machine, so it can be removed.
+func (d *Driver) disableProtection(ctx context.Context) error {
+	log.Warnf("Machine ID='%d' is protected, disabling its protection to remove it...", *d.PVEMachineID)
+
+	err := d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+		return vm.Config(ctx, proxmox.VirtualMachineOption{
+			Name:  "protection",
+			Value: 0,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to disable protection of Proxmox VE virtual machine ID='%d': %w", *d.PVEMachineID, err)
+	}
+
+	return nil
+}
+
 // Runs a task on the current machine.
 func (d *Driver) runTaskOnCurrentMachine(ctx context.Context, callback TaskCallback) error {
 	machine, err := d.getCurrentMachine(ctx)
@@ -55,7 +99,8 @@ func (d *Driver) runTaskOnCurrentMachine(ctx context.Context, callback TaskCallb
 }
 
 // Runs command on the current machine.
-func (d *Driver) runCommandOnCurrentMachine(command string) error {
+// Connection is closed once a given context is done, so that a hung command does not block.
+func (d *Driver) runCommandOnCurrentMachine(ctx context.Context, command string) error {
 	hostname, err := d.GetSSHHostname()
 	if err != nil {
 		return fmt.Errorf("failed to get machine SSH hostname: %w", err)
@@ -75,12 +120,39 @@ func (d *Driver) runCommandOnCurrentMachine(command string) error {
 		return fmt.Errorf("failed to create SSH config: %w", err)
 	}
 
-	connection, err := ssh.Dial("tcp", net.JoinHostPort(hostname, strconv.Itoa(port)), &sshConfig)
+	sshConfig.Timeout = sshConnectTimeout
+	address := net.JoinHostPort(hostname, strconv.Itoa(port))
+	dialer := net.Dialer{Timeout: sshConfig.Timeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
 	if err != nil {
 		return fmt.Errorf("failed to dial SSH: %w", err)
 	}
+	defer conn.Close()
+
+	// Closing the underlying connection interrupts the handshake, the session and the command
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
+	// Handshake is bounded by the timeout too, unlike with ssh.Dial
+	if err := conn.SetDeadline(time.Now().Add(sshConfig.Timeout)); err != nil {
+		return fmt.Errorf("failed to set SSH handshake deadline: %w", err)
+	}
+
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, address, &sshConfig)
+	if err != nil {
+		return fmt.Errorf("failed to dial SSH: %w", err)
+	}
+
+	connection := ssh.NewClient(clientConn, channels, requests)
 	defer connection.Close()
 
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return fmt.Errorf("failed to clear SSH handshake deadline: %w", err)
+	}
+
 	session, err := connection.NewSession()
 	if err != nil {
 		return fmt.Errorf("failed to open SSH session: %w", err)
@@ -93,6 +165,10 @@ func (d *Driver) runCommandOnCurrentMachine(command string) error {
 			return fmt.Errorf("%w: %w", ErrNonZeroExitCode, err)
 		}
 
+		if ctx.Err() != nil {
+			return fmt.Errorf("interrupted executing command: %w", ctx.Err())
+		}
+
 		return fmt.Errorf("failed to execute command: %w", err)
 	}
 
@@ -0,0 +1,76 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/rancher/machine/libmachine/log"
+)
+
+// Migrate migrates the current machine to a given node.
+// Running machine is migrated only if online is set. Local disks are moved to targetStorage, if set.
+func (d *Driver) Migrate(ctx context.Context, targetNodeName string, online bool, targetStorageName string) error {
+	machine, err := d.getCurrentMachine(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to migrate the machine: %w", err)
+	}
+
+	if machine.Node == targetNodeName {
+		return fmt.Errorf("machine is already on node '%s'", targetNodeName)
+	}
+
+	if machine.IsRunning() && !online {
+		return fmt.Errorf("machine is running, enable online migration to migrate it to node '%s'", targetNodeName)
+	}
+
+	targetNode, err := d.getPVENodeStatus(ctx, targetNodeName)
+	if err != nil {
+		return err
+	}
+
+	if targetNode.Status != pveNodeStatusOnline {
+		return fmt.Errorf("target node '%s' is not online (status '%s')", targetNodeName, targetNode.Status)
+	}
+
+	if targetStorageName != "" {
+		if err := d.checkPVEStorage(ctx, targetNodeName, targetStorageName, pveStorageContentImages); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Migrating the machine from node '%s' to node '%s'...", machine.Node, targetNodeName)
+
+	// Local disks of running machine are migrated only on request, shared disks are left untouched
+	err = d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+		return vm.Migrate(ctx, &proxmox.VirtualMachineMigrateOptions{
+			Target:         targetNodeName,
+			Online:         proxmox.IntOrBool(online),
+			TargetStorage:  targetStorageName,
+			WithLocalDisks: proxmox.IntOrBool(online || targetStorageName != ""),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to migrate the machine to node '%s': %w", targetNodeName, err)
+	}
+
+	// HA manager migrates the machine asynchronously once the task requesting the migration ends
+	if machine.HA.Managed != 0 {
+		log.Infof("Migration of the machine to node '%s' was requested from HA manager", targetNodeName)
+		return nil
+	}
+
+	// Node is resolved from the pool membership, so the machine is found on the new node
+	machine, err = d.getCurrentMachine(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve the machine after migration: %w", err)
+	}
+
+	if machine.Node != targetNodeName {
+		return fmt.Errorf("machine is on node '%s' after migration, expected node '%s'", machine.Node, targetNodeName)
+	}
+
+	log.Infof("Machine was migrated to node '%s'", targetNodeName)
+
+	return nil
+}
@@ -0,0 +1,295 @@
+package driver
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/rancher/machine/libmachine/log"
+)
+
+// Strategies for automatic node placement.
+const (
+	placementLeastMemory = "least-memory"
+	placementLeastCPU    = "least-cpu"
+	placementRoundRobin  = "round-robin"
+	placementRandom      = "random"
+)
+
+// Prefix of the tag marking membership of a machine in an anti-affinity group.
+const pveAntiAffinityTagPrefix = "docker-machine-anti-affinity-"
+
+// Available strategies for automatic node placement.
+var placementStrategies = []string{
+	placementLeastMemory,
+	placementLeastCPU,
+	placementRoundRobin,
+	placementRandom,
+}
+
+// Options for selecting a node from cluster resources.
+type placementOptions struct {
+	// Strategy for choosing between candidate nodes.
+	Strategy string
+
+	// If set, only these nodes are considered.
+	AllowedNodeNames []string
+
+	// If set, nodes running a machine with this tag are avoided.
+	AntiAffinityTag string
+
+	// Fails instead of falling back to nodes violating anti-affinity.
+	StrictAntiAffinity bool
+}
+
+// Checks that the machine can be placed according to the current configuration.
+func (d *Driver) checkPlacement(ctx context.Context, template *proxmox.VirtualMachine) error {
+	// Check target node
+	if d.TargetNodeName != "" {
+		targetNode, err := d.getPVENodeStatus(ctx, d.TargetNodeName)
+		if err != nil {
+			return err
+		}
+
+		if targetNode.Status != pveNodeStatusOnline {
+			return fmt.Errorf("target node '%s' is not online (status '%s')", d.TargetNodeName, targetNode.Status)
+		}
+
+		log.Debugf("Using target node '%s'", d.TargetNodeName)
+	}
+
+	// Check placement candidate nodes
+	for _, nodeName := range d.PlacementNodeNames {
+		if _, err := d.getPVENodeStatus(ctx, nodeName); err != nil {
+			return fmt.Errorf("placement candidate node '%s' is invalid: %w", nodeName, err)
+		}
+	}
+
+	if d.Placement != "" {
+		log.Debugf("Using '%s' placement", d.Placement)
+	}
+
+	// Check storages, node selected by automatic placement is checked during creation
+	if d.Placement == "" {
+		storageNodeName := d.TargetNodeName
+		if storageNodeName == "" {
+			storageNodeName = template.Node
+		}
+
+		if err := d.checkRequiredPVEStorages(ctx, storageNodeName); err != nil {
+			return err
+		}
+	}
+
+	if d.StorageName != "" {
+		log.Debugf("Using storage '%s' for disks", d.StorageName)
+	}
+
+	if d.AntiAffinityGroup != "" {
+		log.Debugf("Using anti-affinity group '%s' (strict: %t)", d.AntiAffinityGroup, d.StrictAntiAffinity)
+	}
+
+	return nil
+}
+
+// Returns name of the node the machine should be cloned onto.
+// Empty name means the node of the template.
+func (d *Driver) getTargetNodeName(ctx context.Context) (string, error) {
+	if d.Placement == "" && d.AntiAffinityGroup == "" {
+		return d.TargetNodeName, nil
+	}
+
+	cluster, err := d.getPVEClient().Cluster(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve Proxmox VE cluster: %w", err)
+	}
+
+	resources, err := cluster.Resources(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve Proxmox VE cluster resources: %w", err)
+	}
+
+	if d.Placement == "" {
+		return d.TargetNodeName, d.checkFixedNodeAntiAffinity(ctx, resources)
+	}
+
+	nodeName, reason, err := selectPVENode(resources, placementOptions{
+		Strategy:           d.Placement,
+		AllowedNodeNames:   d.PlacementNodeNames,
+		AntiAffinityTag:    d.getAntiAffinityTag(),
+		StrictAntiAffinity: d.StrictAntiAffinity,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to select target node using '%s' placement: %w", d.Placement, err)
+	}
+
+	log.Infof("Placing the machine on node '%s': %s", nodeName, reason)
+
+	return nodeName, nil
+}
+
+// Checks anti-affinity for the target node, or the node of the template if no target node is set.
+func (d *Driver) checkFixedNodeAntiAffinity(ctx context.Context, resources proxmox.ClusterResources) error {
+	nodeName := d.TargetNodeName
+
+	if nodeName == "" {
+		template, err := d.getPVETemplate(ctx)
+		if err != nil {
+			return err
+		}
+
+		nodeName = template.Node
+	}
+
+	if !getAntiAffinityNodeNames(resources, d.getAntiAffinityTag())[nodeName] {
+		return nil
+	}
+
+	if d.StrictAntiAffinity {
+		return fmt.Errorf("node '%s' already runs a machine from anti-affinity group '%s'", nodeName, d.AntiAffinityGroup)
+	}
+
+	log.Warnf("Node '%s' already runs a machine from anti-affinity group '%s', placing the machine there anyway", nodeName, d.AntiAffinityGroup)
+
+	return nil
+}
+
+// Returns tag of the current anti-affinity group, or empty string if the group is not set.
+func (d *Driver) getAntiAffinityTag() string {
+	if d.AntiAffinityGroup == "" {
+		return ""
+	}
+
+	return pveAntiAffinityTagPrefix + d.AntiAffinityGroup
+}
+
+// Returns names of nodes running a machine with a given anti-affinity tag.
+func getAntiAffinityNodeNames(resources proxmox.ClusterResources, tag string) map[string]bool {
+	nodeNames := map[string]bool{}
+
+	if tag == "" {
+		return nodeNames
+	}
+
+	for _, resource := range resources {
+		if resource.Type == "qemu" && resource.Status == proxmox.StatusVirtualMachineRunning && hasPVETag(resource.Tags, tag) {
+			nodeNames[resource.Node] = true
+		}
+	}
+
+	return nodeNames
+}
+
+// Selects the best node from cluster resources.
+// Returns the node name together with a human-readable reason for the choice.
+//
+//nolint:cyclop
+func selectPVENode(resources proxmox.ClusterResources, options placementOptions) (string, string, error) {
+	candidates := make([]*proxmox.ClusterResource, 0)
+	machineCount := map[string]int{}
+
+	for _, resource := range resources {
+		switch resource.Type {
+		case "node":
+			if resource.Status != pveNodeStatusOnline {
+				continue
+			}
+
+			if len(options.AllowedNodeNames) > 0 && !slices.Contains(options.AllowedNodeNames, resource.Node) {
+				continue
+			}
+
+			candidates = append(candidates, resource)
+		case "qemu":
+			if resource.Template == 0 && hasPVETag(resource.Tags, pveMachineTag) {
+				machineCount[resource.Node]++
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return "", "", errors.New("no online candidate nodes found")
+	}
+
+	antiAffinityNote := ""
+
+	if antiAffinityNodeNames := getAntiAffinityNodeNames(resources, options.AntiAffinityTag); len(antiAffinityNodeNames) > 0 {
+		preferredCandidates := slices.DeleteFunc(slices.Clone(candidates), func(candidate *proxmox.ClusterResource) bool {
+			return antiAffinityNodeNames[candidate.Node]
+		})
+
+		switch {
+		case len(preferredCandidates) > 0:
+			antiAffinityNote = fmt.Sprintf(", skipped %d node(s) violating anti-affinity", len(candidates)-len(preferredCandidates))
+			candidates = preferredCandidates
+		case options.StrictAntiAffinity:
+			return "", "", errors.New("all candidate nodes already run a machine from the anti-affinity group")
+		default:
+			antiAffinityNote = ", anti-affinity could not be satisfied"
+		}
+	}
+
+	// Stable order, so ties are always broken the same way
+	slices.SortFunc(candidates, func(a, b *proxmox.ClusterResource) int {
+		return strings.Compare(a.Node, b.Node)
+	})
+
+	nodeName, reason, err := selectPVENodeByStrategy(options.Strategy, candidates, machineCount)
+
+	return nodeName, reason + antiAffinityNote, err
+}
+
+// Selects the best node from candidates using a given strategy.
+func selectPVENodeByStrategy(strategy string, candidates []*proxmox.ClusterResource, machineCount map[string]int) (string, string, error) {
+	switch strategy {
+	case placementLeastMemory:
+		best := slices.MinFunc(candidates, func(a, b *proxmox.ClusterResource) int {
+			return cmp.Compare(usageFraction(a.Mem, a.MaxMem), usageFraction(b.Mem, b.MaxMem))
+		})
+
+		//nolint:mnd
+		return best.Node, fmt.Sprintf("lowest memory usage (%.1f%%) of %d candidate node(s)", usageFraction(best.Mem, best.MaxMem)*100, len(candidates)), nil
+	case placementLeastCPU:
+		best := slices.MinFunc(candidates, func(a, b *proxmox.ClusterResource) int {
+			return cmp.Compare(a.CPU, b.CPU)
+		})
+
+		//nolint:mnd
+		return best.Node, fmt.Sprintf("lowest CPU usage (%.1f%%) of %d candidate node(s)", best.CPU*100, len(candidates)), nil
+	case placementRoundRobin:
+		fewest := machineCount[slices.MinFunc(candidates, func(a, b *proxmox.ClusterResource) int {
+			return machineCount[a.Node] - machineCount[b.Node]
+		}).Node]
+
+		// Concurrent creations see the same counts, so ties are broken randomly to spread them across nodes
+		tied := slices.DeleteFunc(slices.Clone(candidates), func(candidate *proxmox.ClusterResource) bool {
+			return machineCount[candidate.Node] != fewest
+		})
+
+		//nolint:gosec // Weak number generator is good enough for this case
+		chosen := tied[rand.Intn(len(tied))]
+
+		return chosen.Node, fmt.Sprintf("fewest driver-managed machines (%d), picked randomly from %d such of %d candidate node(s)", fewest, len(tied), len(candidates)), nil
+	case placementRandom:
+		//nolint:gosec // Weak number generator is good enough for this case
+		chosen := candidates[rand.Intn(len(candidates))]
+
+		return chosen.Node, fmt.Sprintf("picked randomly from %d candidate node(s)", len(candidates)), nil
+	default:
+		return "", "", fmt.Errorf("unknown placement strategy '%s'", strategy)
+	}
+}
+
+// Returns fraction of used capacity, or 1 if the total capacity is unknown.
+func usageFraction(used, total uint64) float64 {
+	if total == 0 {
+		return 1
+	}
+
+	return float64(used) / float64(total)
+}
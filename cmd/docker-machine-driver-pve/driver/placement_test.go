@@ -0,0 +1,99 @@
+package driver
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_selectPVENode(t *testing.T) {
+	resources := proxmox.ClusterResources{
+		{Type: "node", Node: "pve1", Status: "online", CPU: 0.50, Mem: 6, MaxMem: 10},
+		{Type: "node", Node: "pve2", Status: "online", CPU: 0.10, Mem: 8, MaxMem: 10},
+		{Type: "node", Node: "pve3", Status: "online", CPU: 0.30, Mem: 2, MaxMem: 10},
+		{Type: "node", Node: "pve4", Status: "offline"},
+		{Type: "qemu", Node: "pve1", Status: "running", Tags: "docker-machine"},
+		{Type: "qemu", Node: "pve3", Status: "running", Tags: "docker-machine;docker-machine-anti-affinity-etcd"},
+		{Type: "qemu", Node: "pve3", Status: "running", Tags: "docker-machine"},
+		{Type: "qemu", Node: "pve2", Status: "stopped", Tags: "docker-machine-anti-affinity-etcd;other"},
+	}
+
+	tests := map[string]struct {
+		options      placementOptions
+		expectedNode string
+	}{
+		"least memory": {
+			options:      placementOptions{Strategy: placementLeastMemory},
+			expectedNode: "pve3",
+		},
+		"least CPU": {
+			options:      placementOptions{Strategy: placementLeastCPU},
+			expectedNode: "pve2",
+		},
+		"round robin": {
+			options:      placementOptions{Strategy: placementRoundRobin},
+			expectedNode: "pve2",
+		},
+		"least memory with allow-list": {
+			options:      placementOptions{Strategy: placementLeastMemory, AllowedNodeNames: []string{"pve1", "pve2"}},
+			expectedNode: "pve1",
+		},
+		"round robin with allow-list": {
+			options:      placementOptions{Strategy: placementRoundRobin, AllowedNodeNames: []string{"pve1", "pve3"}},
+			expectedNode: "pve1",
+		},
+		"random with single candidate": {
+			options:      placementOptions{Strategy: placementRandom, AllowedNodeNames: []string{"pve2", "pve4"}},
+			expectedNode: "pve2",
+		},
+		"least memory with anti-affinity": {
+			options:      placementOptions{Strategy: placementLeastMemory, AntiAffinityTag: "docker-machine-anti-affinity-etcd"},
+			expectedNode: "pve1",
+		},
+		"anti-affinity fallback": {
+			options:      placementOptions{Strategy: placementLeastMemory, AllowedNodeNames: []string{"pve3"}, AntiAffinityTag: "docker-machine-anti-affinity-etcd"},
+			expectedNode: "pve3",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			node, _, err := selectPVENode(resources, test.options)
+			require.NoError(t, err)
+			require.Equal(t, test.expectedNode, node)
+		})
+	}
+
+	t.Run("round robin with tie", func(t *testing.T) {
+		pickedNodes := map[string]bool{}
+
+		// Both pve1 and pve2 run a single machine, pve3 runs two
+		tiedResources := append(slices.Clone(resources), &proxmox.ClusterResource{Type: "qemu", Node: "pve2", Status: "running", Tags: "docker-machine"})
+
+		for range 100 {
+			node, _, err := selectPVENode(tiedResources, placementOptions{Strategy: placementRoundRobin})
+			require.NoError(t, err)
+
+			pickedNodes[node] = true
+		}
+
+		require.Equal(t, map[string]bool{"pve1": true, "pve2": true}, pickedNodes)
+	})
+
+	t.Run("no online candidates", func(t *testing.T) {
+		_, _, err := selectPVENode(resources, placementOptions{Strategy: placementLeastCPU, AllowedNodeNames: []string{"pve4"}})
+		require.Error(t, err)
+	})
+
+	t.Run("strict anti-affinity", func(t *testing.T) {
+		_, _, err := selectPVENode(resources, placementOptions{
+			Strategy:           placementLeastMemory,
+			AllowedNodeNames:   []string{"pve3"},
+			AntiAffinityTag:    "docker-machine-anti-affinity-etcd",
+			StrictAntiAffinity: true,
+		})
+		require.Error(t, err)
+	})
+}
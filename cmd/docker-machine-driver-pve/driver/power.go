@@ -0,0 +1,227 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/rancher/machine/libmachine/log"
+	"github.com/rancher/machine/libmachine/state"
+)
+
+// Lock of a Proxmox VE virtual machine suspended to disk.
+const pveLockSuspended = "suspended"
+
+// Returns state of the machine corresponding to status of a given Proxmox VE virtual machine.
+// Running machine might still be starting, which can be told only by its guest agent.
+func getMachineState(vm *proxmox.VirtualMachine) (state.State, error) {
+	switch vm.Status {
+	case proxmox.StatusVirtualMachineStopped:
+		if vm.Lock == pveLockSuspended {
+			return state.Saved, nil
+		}
+
+		return state.Stopped, nil
+	case proxmox.StatusVirtualMachineRunning:
+	default:
+		return state.Error, fmt.Errorf("unknown status '%s' of Proxmox VE virtual machine ID='%d'", vm.Status, vm.VMID)
+	}
+
+	switch vm.QMPStatus {
+	case proxmox.StatusVirtualMachinePaused, "prelaunch", "suspended":
+		return state.Paused, nil
+	case "shutdown":
+		return state.Stopping, nil
+	case "internal-error", "io-error", "guest-panicked":
+		return state.Error, fmt.Errorf("virtual machine ID='%d' failed with QEMU status '%s'", vm.VMID, vm.QMPStatus)
+	}
+
+	return state.Running, nil
+}
+
+// Suspend suspends the current machine to RAM, or to disk if toDisk is set.
+func (d *Driver) Suspend(ctx context.Context, toDisk bool) error {
+	target := "RAM"
+	if toDisk {
+		target = "disk"
+	}
+
+	log.Infof("Suspending the machine to %s...", target)
+
+	err := d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+		if toDisk {
+			return vm.Hibernate(ctx)
+		}
+
+		return vm.Pause(ctx)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to suspend the machine: %w", err)
+	}
+
+	return nil
+}
+
+// Resume resumes the current machine suspended to RAM or disk.
+func (d *Driver) Resume(ctx context.Context) error {
+	log.Info("Resuming the machine...")
+
+	// Proxmox VE resumes machine suspended to disk only on start
+	err := d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+		if vm.Lock == pveLockSuspended {
+			return vm.Start(ctx)
+		}
+
+		return vm.Resume(ctx)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to resume the machine: %w", err)
+	}
+
+	return nil
+}
+
+// Shuts down the current machine, preferring the guest agent over ACPI.
+// Forces the machine off once the shutdown timeout expires, if allowed.
+func (d *Driver) shutdown(ctx context.Context) error {
+	machine, err := d.getCurrentMachine(ctx)
+	if err != nil {
+		return err
+	}
+
+	if machineState, _ := getMachineState(machine); machineState == state.Stopped || machineState == state.Saved {
+		log.Debugf("Machine is already %s", strings.ToLower(machineState.String()))
+		return nil
+	}
+
+	// Agent and ACPI shutdown share the timeout, so that the fallback does not extend it
+	deadline := time.Now().Add(d.getShutdownTimeout())
+	stopped := false
+	useAgent := d.isGuestAgentRunning(ctx, machine)
+
+	if useAgent {
+		if stopped, err = d.shutdownViaAgent(ctx, machine, deadline); err != nil {
+			log.Warnf("Failed to shut down the machine via guest agent, falling back to ACPI: %s", err.Error())
+
+			useAgent = false
+		}
+	} else {
+		log.Debug("Guest agent is not running, using ACPI shutdown")
+	}
+
+	if !useAgent {
+		if stopped, err = d.shutdownViaACPI(ctx, machine, deadline); err != nil {
+			log.Warnf("Failed to shut down the machine via ACPI: %s", err.Error())
+		}
+	}
+
+	if stopped {
+		log.Info("Machine was shut down")
+		return nil
+	}
+
+	if !d.ShutdownForce {
+		return fmt.Errorf("machine did not shut down within %s, set '--%s' to force it off", d.getShutdownTimeout(), flagShutdownForce)
+	}
+
+	log.Warnf("Machine did not shut down within %s, forcing it off...", d.getShutdownTimeout())
+
+	err = d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+		return vm.Stop(ctx)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to force the machine off: %w", err)
+	}
+
+	log.Info("Machine was forced off")
+
+	return nil
+}
+
+// Returns time to wait for the machine to shut down.
+func (d *Driver) getShutdownTimeout() time.Duration {
+	return durationOrDefault(d.ShutdownTimeout, pveTaskPollingTimeout)
+}
+
+// Checks whether the guest agent responds on a given virtual machine.
+func (d *Driver) isGuestAgentRunning(ctx context.Context, vm *proxmox.VirtualMachine) bool {
+	_, err := vm.AgentOsInfo(ctx)
+
+	return err == nil
+}
+
+// Requests shutdown of a given virtual machine via the guest agent.
+// Returns whether the machine stopped before a given deadline.
+func (d *Driver) shutdownViaAgent(ctx context.Context, vm *proxmox.VirtualMachine, deadline time.Time) (bool, error) {
+	timeout := time.Until(deadline).Round(time.Second)
+
+	log.Infof("Requesting shutdown via guest agent, waiting up to %s...", timeout)
+
+	if err := d.getPVEClient().Post(ctx, fmt.Sprintf("/nodes/%s/qemu/%d/agent/shutdown", vm.Node, vm.VMID), nil, nil); err != nil {
+		return false, err
+	}
+
+	return d.waitForMachineToStop(ctx, timeout)
+}
+
+// Requests ACPI shutdown of a given virtual machine.
+// Returns whether the machine stopped before a given deadline.
+func (d *Driver) shutdownViaACPI(ctx context.Context, vm *proxmox.VirtualMachine, deadline time.Time) (bool, error) {
+	timeout := time.Until(deadline).Round(time.Second)
+	if timeout <= 0 {
+		return false, errors.New("no time left of the shutdown timeout")
+	}
+
+	log.Infof("Requesting ACPI shutdown, waiting up to %s...", timeout)
+
+	var upid proxmox.UPID
+
+	// Proxmox VE holds lock of the machine until the shutdown task ends, so it must not outlive the timeout
+	err := d.getPVEClient().Post(ctx, fmt.Sprintf("/nodes/%s/qemu/%d/status/shutdown", vm.Node, vm.VMID), map[string]interface{}{
+		"timeout": int(timeout.Seconds()),
+	}, &upid)
+	if err != nil {
+		return false, err
+	}
+
+	// Shutdown task itself lasts up to the shutdown timeout, which can exceed the default task timeout
+	taskCtx, cancel := context.WithTimeout(ctx, timeout+d.getPollingInterval())
+	defer cancel()
+
+	if err := d.waitForPVETaskToSucceed(taskCtx, proxmox.NewTask(upid, d.getPVEClient())); err != nil {
+		log.Debugf("Shutdown task did not succeed: %s", err.Error())
+	}
+
+	return d.waitForMachineToStop(ctx, 0)
+}
+
+// Blocks until the current machine stops or a given timeout expires.
+// Returns whether the machine stopped.
+func (d *Driver) waitForMachineToStop(ctx context.Context, timeout time.Duration) (bool, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		machine, err := d.getCurrentMachine(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		if machine.IsStopped() {
+			return true, nil
+		}
+
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(d.getPollingInterval()):
+			continue
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package driver
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/rancher/machine/libmachine/state"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getMachineState(t *testing.T) {
+	// Responses of '/nodes/{node}/qemu/{vmid}/status/current'
+	tests := map[string]state.State{
+		`{"vmid": 5000, "status": "stopped"}`:                                           state.Stopped,
+		`{"vmid": 5000, "status": "stopped", "lock": "suspended"}`:                      state.Saved,
+		`{"vmid": 5000, "status": "running", "qmpstatus": "running"}`:                   state.Running,
+		`{"vmid": 5000, "status": "running"}`:                                           state.Running,
+		`{"vmid": 5000, "status": "running", "qmpstatus": "paused"}`:                    state.Paused,
+		`{"vmid": 5000, "status": "running", "qmpstatus": "prelaunch"}`:                 state.Paused,
+		`{"vmid": 5000, "status": "running", "qmpstatus": "suspended"}`:                 state.Paused,
+		`{"vmid": 5000, "status": "running", "qmpstatus": "shutdown"}`:                  state.Stopping,
+		`{"vmid": 5000, "status": "running", "qmpstatus": "inmigrate"}`:                 state.Running,
+		`{"vmid": 5000, "status": "running", "qmpstatus": "running", "lock": "backup"}`: state.Running,
+	}
+
+	for response, expectedState := range tests {
+		t.Run(response, func(t *testing.T) {
+			vm := &proxmox.VirtualMachine{}
+			require.NoError(t, json.Unmarshal([]byte(response), vm))
+
+			machineState, err := getMachineState(vm)
+			require.NoError(t, err)
+			require.Equal(t, expectedState, machineState)
+		})
+	}
+
+	for _, response := range []string{
+		`{"vmid": 5000, "status": "unknown"}`,
+		`{"vmid": 5000, "status": "running", "qmpstatus": "io-error"}`,
+		`{"vmid": 5000, "status": "running", "qmpstatus": "guest-panicked"}`,
+	} {
+		t.Run(response, func(t *testing.T) {
+			vm := &proxmox.VirtualMachine{}
+			require.NoError(t, json.Unmarshal([]byte(response), vm))
+
+			machineState, err := getMachineState(vm)
+			require.Error(t, err)
+			require.Equal(t, state.Error, machineState)
+		})
+	}
+}
@@ -3,34 +3,93 @@ package driver
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"math"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/luthermonson/go-proxmox"
+	"github.com/rancher/machine/libmachine/log"
 )
 
 const (
-	// Polling interval for Proxmox task status.
+	// Default polling interval for Proxmox task status.
 	pveTaskPollingInterval = 3 * time.Second
 
-	// Polling timeout for Proxmox task status.
+	// Default polling timeout for Proxmox task status.
 	pveTaskPollingTimeout = 10 * time.Minute
+
+	// Timeout for stopping an interrupted Proxmox task.
+	pveTaskStopTimeout = 3 * time.Second
+
+	// Timeout for removing a machine whose creation was interrupted. Plugin process is killed once it misses
+	// a heartbeat of the interrupted parent process, i.e. within 5 to 10 seconds.
+	pveInterruptedCleanupTimeout = 4 * time.Second
+
+	// Status of a Proxmox VE node that is up and part of the quorate cluster.
+	pveNodeStatusOnline = "online"
+
+	// Storage content type for virtual machine disk images.
+	pveStorageContentImages = "images"
 )
 
+// Error returned when a Proxmox VE virtual machine is not found in the current resource pool.
+var ErrPVEVirtualMachineNotFound = errors.New("not found")
+
+// Cause of Proxmox VE error when a virtual machine is created with ID that is already used.
+const pveCauseConfigFileExists = "config file already exists"
+
+// Returns whether a given error is caused by creating a Proxmox VE virtual machine with ID that is already used.
+func isPVEVirtualMachineIDConflict(err error) bool {
+	var taskError *TaskError
+	if errors.As(err, &taskError) {
+		return taskError.HasCause(pveCauseConfigFileExists)
+	}
+
+	// Client exposes errors of synchronous requests only as HTTP status line, which holds the message
+	return strings.Contains(err.Error(), pveCauseConfigFileExists)
+}
+
 // Creates a new Proxmox VE virtual machine from the current template.
-func (d *Driver) createPVEVirtualMachine(ctx context.Context) (int, error) {
+// IDs in excludedIDs are skipped when allocating ID from the configured range.
+func (d *Driver) createPVEVirtualMachine(ctx context.Context, excludedIDs map[int]bool) (int, error) {
 	template, err := d.getPVETemplate(ctx)
 	if err != nil {
 		return -1, err
 	}
 
+	targetNodeName, err := d.getTargetNodeName(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	// Storages of a fixed target node are already checked in PreCreateCheck()
+	if d.Placement != "" {
+		if err := d.checkRequiredPVEStorages(ctx, targetNodeName); err != nil {
+			return -1, err
+		}
+	}
+
+	newID, err := d.getFreeVMID(ctx, excludedIDs)
+	if err != nil {
+		return -1, err
+	}
+
 	vmid, task, err := template.Clone(ctx, &proxmox.VirtualMachineCloneOptions{
-		Name: d.MachineName,
-		Pool: d.ResourcePoolName,
-		Full: map[bool]uint8{false: 0, true: 1}[d.FullClone],
+		NewID:       newID,
+		Name:        d.MachineName,
+		Description: getPVEMachineDescription(template.VirtualMachineConfig.Description),
+		Pool:        d.ResourcePoolName,
+		Full:        map[bool]uint8{false: 0, true: 1}[d.FullClone],
+		Target:      targetNodeName,
+		Storage:     d.StorageName,
+		Format:      d.StorageFormat,
 	})
 	if err != nil {
 		return vmid, fmt.Errorf("failed to clone template ID='%d': %w", d.TemplateID, err)
@@ -43,8 +102,66 @@ func (d *Driver) createPVEVirtualMachine(ctx context.Context) (int, error) {
 	return vmid, nil
 }
 
+// Returns a free ID from the configured range, or 0 to let Proxmox VE allocate the next free ID.
+func (d *Driver) getFreeVMID(ctx context.Context, excludedIDs map[int]bool) (int, error) {
+	if d.VMIDRangeMin == 0 {
+		return 0, nil
+	}
+
+	cluster, err := d.getPVEClient().Cluster(ctx)
+	if err != nil {
+		return -1, fmt.Errorf("failed to retrieve Proxmox VE cluster: %w", err)
+	}
+
+	resources, err := cluster.Resources(ctx, "vm")
+	if err != nil {
+		return -1, fmt.Errorf("failed to retrieve Proxmox VE cluster resources: %w", err)
+	}
+
+	usedIDs := maps.Clone(excludedIDs)
+	if usedIDs == nil {
+		usedIDs = map[int]bool{}
+	}
+
+	for _, resource := range resources {
+		if resource.VMID <= math.MaxInt {
+			usedIDs[int(resource.VMID)] = true
+		}
+	}
+
+	vmid, found := findFreeVMID(d.VMIDRangeMin, d.VMIDRangeMax, usedIDs)
+	if !found {
+		return -1, fmt.Errorf("no free ID left in range %d-%d", d.VMIDRangeMin, d.VMIDRangeMax)
+	}
+
+	log.Debugf("Using ID='%d' from range %d-%d", vmid, d.VMIDRangeMin, d.VMIDRangeMax)
+
+	return vmid, nil
+}
+
+// Returns the lowest ID in range [minID, maxID] that is not used.
+func findFreeVMID(minID, maxID int, usedIDs map[int]bool) (int, bool) {
+	for vmid := minID; vmid <= maxID; vmid++ {
+		if !usedIDs[vmid] {
+			return vmid, true
+		}
+	}
+
+	return -1, false
+}
+
 // Returns the current Proxmox VE template.
+// Template selector is resolved only once, so all later operations use the same template.
 func (d *Driver) getPVETemplate(ctx context.Context) (*proxmox.VirtualMachine, error) {
+	if d.TemplateID == 0 && d.TemplateSelector != "" {
+		templateID, err := d.resolvePVETemplateSelector(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve Proxmox VE template: %w", err)
+		}
+
+		d.TemplateID = templateID
+	}
+
 	template, err := d.getPVEVirtualMachine(ctx, d.TemplateID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve Proxmox VE template: %w", err)
@@ -54,6 +171,8 @@ func (d *Driver) getPVETemplate(ctx context.Context) (*proxmox.VirtualMachine, e
 }
 
 // Returns a Proxmox VE virtual machine from the current resource pool.
+// Node is resolved from the pool membership, so machines cloned onto
+// (or later moved to) a different node than the template are still found.
 func (d *Driver) getPVEVirtualMachine(ctx context.Context, vmid int) (*proxmox.VirtualMachine, error) {
 	resourcePool, err := d.getCurrentPVEResourcePool(ctx)
 	if err != nil {
@@ -72,7 +191,7 @@ func (d *Driver) getPVEVirtualMachine(ctx context.Context, vmid int) (*proxmox.V
 		return d.getPVEVirtualMachineOnNode(ctx, vmid, member.Node)
 	}
 
-	return nil, fmt.Errorf("failed to retrieve Proxmox VE virtual machine ID='%d' in resource pool name='%s': not found", vmid, d.ResourcePoolName)
+	return nil, fmt.Errorf("failed to retrieve Proxmox VE virtual machine ID='%d' in resource pool name='%s': %w", vmid, d.ResourcePoolName, ErrPVEVirtualMachineNotFound)
 }
 
 // Returns Proxmox VE virtual machine from a given node.
@@ -90,6 +209,108 @@ func (d *Driver) getPVEVirtualMachineOnNode(ctx context.Context, vmid int, nodeN
 	return vm, nil
 }
 
+// Deletes a Proxmox VE virtual machine with all its disks, including the ones not referenced in its config.
+// The machine is also removed from backup jobs, replication jobs and HA resources.
+func (d *Driver) deletePVEVirtualMachine(ctx context.Context, vm *proxmox.VirtualMachine) error {
+	var upid proxmox.UPID
+
+	err := d.getPVEClient().Delete(ctx, fmt.Sprintf("/nodes/%s/qemu/%d?purge=1&destroy-unreferenced-disks=1", vm.Node, vm.VMID), &upid)
+	if err == nil {
+		// Stopped deletion would leave the machine half-deleted, so it is left to finish in Proxmox VE
+		err = d.waitForPVETask(ctx, proxmox.NewTask(upid, d.getPVEClient()), false)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to delete Proxmox VE virtual machine ID='%d': %w", vm.VMID, err)
+	}
+
+	log.Debugf("Deleted Proxmox VE virtual machine ID='%d'", vm.VMID)
+
+	return nil
+}
+
+// Removes a Proxmox VE virtual machine from HA resources, if it is managed by HA.
+func (d *Driver) removePVEVirtualMachineFromHA(ctx context.Context, vm *proxmox.VirtualMachine) error {
+	if vm.HA.Managed == 0 {
+		return nil
+	}
+
+	if err := d.getPVEClient().Delete(ctx, fmt.Sprintf("/cluster/ha/resources/vm:%d", vm.VMID), nil); err != nil {
+		return fmt.Errorf("failed to remove Proxmox VE virtual machine ID='%d' from HA resources: %w", vm.VMID, err)
+	}
+
+	log.Debugf("Removed Proxmox VE virtual machine ID='%d' from HA resources", vm.VMID)
+
+	return nil
+}
+
+// Returns status of a Proxmox VE node with a given name.
+func (d *Driver) getPVENodeStatus(ctx context.Context, nodeName string) (*proxmox.NodeStatus, error) {
+	nodes, err := d.getPVEClient().Nodes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve Proxmox VE nodes: %w", err)
+	}
+
+	for _, node := range nodes {
+		if node.Node == nodeName {
+			return node, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to retrieve Proxmox VE node name='%s': not found", nodeName)
+}
+
+// Returns names of storages the machine needs for its disks, besides storages of the template.
+func (d *Driver) getRequiredStorageNames() []string {
+	storageNames := []string{}
+
+	if d.StorageName != "" {
+		storageNames = append(storageNames, d.StorageName)
+	}
+
+	for _, disk := range d.ExtraDisks {
+		if !slices.Contains(storageNames, disk.Storage) {
+			storageNames = append(storageNames, disk.Storage)
+		}
+	}
+
+	return storageNames
+}
+
+// Checks that storages the machine needs are usable on a given node.
+func (d *Driver) checkRequiredPVEStorages(ctx context.Context, nodeName string) error {
+	for _, storageName := range d.getRequiredStorageNames() {
+		if err := d.checkPVEStorage(ctx, nodeName, storageName, pveStorageContentImages); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Checks that a storage can hold a given type of content on a given node.
+func (d *Driver) checkPVEStorage(ctx context.Context, nodeName, storageName, content string) error {
+	node, err := d.getPVEClient().Node(ctx, nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve Proxmox VE node name='%s': %w", nodeName, err)
+	}
+
+	storage, err := node.Storage(ctx, storageName)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve Proxmox VE storage name='%s' on node name='%s': %w", storageName, nodeName, err)
+	}
+
+	if storage.Enabled == 0 {
+		return fmt.Errorf("storage '%s' is disabled on node '%s'", storageName, nodeName)
+	}
+
+	if !slices.Contains(strings.Split(storage.Content, ","), content) {
+		return fmt.Errorf("storage '%s' on node '%s' does not allow '%s' content", storageName, nodeName, content)
+	}
+
+	return nil
+}
+
 // Returns the current Proxmox VE resource pool.
 func (d *Driver) getCurrentPVEResourcePool(ctx context.Context) (*proxmox.Pool, error) {
 	resourcePool, err := d.getPVEClient().Pool(ctx, d.ResourcePoolName)
@@ -101,25 +322,87 @@ func (d *Driver) getCurrentPVEResourcePool(ctx context.Context) (*proxmox.Pool,
 }
 
 // Blocks until a Proxmox VE task finishes successfully.
+// Nil task is treated as an operation that already finished synchronously.
+// Task is awaited until the deadline of the context, or for the config timeout if the context has no deadline.
+// Task still running once the context is done or the timeout expires is stopped.
 func (d *Driver) waitForPVETaskToSucceed(ctx context.Context, task *proxmox.Task) error {
-	if err := task.Wait(ctx, pveTaskPollingInterval, pveTaskPollingTimeout); err != nil {
+	return d.waitForPVETask(ctx, task, true)
+}
+
+// Blocks until a Proxmox VE task finishes successfully, optionally stopping the task if it is still running
+// once the context is done or the timeout expires.
+func (d *Driver) waitForPVETask(ctx context.Context, task *proxmox.Task, stopUnfinished bool) error {
+	if task == nil {
+		return nil
+	}
+
+	timeout := durationOrDefault(d.ConfigTimeout, pveTaskPollingTimeout)
+	if deadline, found := ctx.Deadline(); found {
+		timeout = time.Until(deadline)
+	}
+
+	if err := task.Wait(ctx, d.getPollingInterval(), timeout); err != nil {
+		// Task would otherwise keep running in Proxmox VE, e.g. holding lock of the machine
+		if stopUnfinished && (ctx.Err() != nil || proxmox.IsTimeout(err)) {
+			d.stopPVETask(ctx, task)
+		}
+
 		return fmt.Errorf("failed waiting for task ID='%s' to complete: %w", task.ID, err)
 	}
 
 	if !task.IsSuccessful {
-		return fmt.Errorf("task ID='%s' failed", task.ID)
+		return d.newTaskError(ctx, task)
 	}
 
 	return nil
 }
 
+// Stops a running Proxmox VE task. Failure is only logged, since the task might have already finished.
+func (d *Driver) stopPVETask(ctx context.Context, task *proxmox.Task) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), pveTaskStopTimeout)
+	defer cancel()
+
+	log.Infof("Stopping unfinished task ID='%s'...", task.UPID)
+
+	if err := task.Stop(ctx); err != nil {
+		log.Warnf("Failed to stop task ID='%s': %s", task.UPID, err.Error())
+	}
+}
+
+// Returns interval of polling Proxmox VE tasks and the machine state.
+func (d *Driver) getPollingInterval() time.Duration {
+	return durationOrDefault(d.PollingInterval, pveTaskPollingInterval)
+}
+
 // Returns a client for Proxmox VE.
 func (d *Driver) getPVEClient() *proxmox.Client {
 	if d.pveClient != nil {
 		return d.pveClient
 	}
 
-	client := http.Client{
+	d.pveClient = proxmox.NewClient(
+		d.getPVEAPIURL(),
+		proxmox.WithAPIToken(d.TokenID, d.TokenSecret),
+		proxmox.WithHTTPClient(d.newPVEHTTPClient()),
+	)
+
+	return d.pveClient
+}
+
+// Returns base URL of Proxmox VE API.
+func (d *Driver) getPVEAPIURL() string {
+	pveURL, err := url.Parse(d.URL)
+	if err != nil {
+		// Note that parsing is already checked in SetConfigFromFlags()
+		panic(fmt.Errorf("failed to parse Proxmox VE URL: %w", err).Error())
+	}
+
+	return pveURL.JoinPath("/api2/json").String()
+}
+
+// Returns a new HTTP client for Proxmox VE API.
+func (d *Driver) newPVEHTTPClient() *http.Client {
+	return &http.Client{
 		Transport: &http.Transport{
 			TLSClientConfig: &tls.Config{
 				//nolint:gosec
@@ -127,18 +410,32 @@ func (d *Driver) getPVEClient() *proxmox.Client {
 			},
 		},
 	}
+}
 
-	pveURL, err := url.Parse(d.URL)
+// Retrieves a given path of Proxmox VE API, decoding the whole response body.
+// Unlike the client, which decodes only the 'data' key, it keeps other keys (e.g. 'total').
+func (d *Driver) getPVERaw(ctx context.Context, path string, v interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, d.getPVEAPIURL()+path, nil)
 	if err != nil {
-		// Note that parsing is already checked in SetConfigFromFlags()
-		panic(fmt.Errorf("failed to parse Proxmox VE URL: %w", err).Error())
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	d.pveClient = proxmox.NewClient(
-		pveURL.JoinPath("/api2/json").String(),
-		proxmox.WithAPIToken(d.TokenID, d.TokenSecret),
-		proxmox.WithHTTPClient(&client),
-	)
+	request.Header.Set("Authorization", fmt.Sprintf("PVEAPIToken=%s=%s", d.TokenID, d.TokenSecret))
 
-	return d.pveClient
+	response, err := d.newPVEHTTPClient().Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("request failed with status '%s'", response.Status)
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return nil
 }
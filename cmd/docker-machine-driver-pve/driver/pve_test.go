@@ -0,0 +1,16 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_findFreeVMID(t *testing.T) {
+	vmid, found := findFreeVMID(100, 103, map[int]bool{100: true, 101: true, 103: true})
+	require.True(t, found)
+	require.Equal(t, 102, vmid)
+
+	_, found = findFreeVMID(100, 101, map[int]bool{100: true, 101: true})
+	require.False(t, found)
+}
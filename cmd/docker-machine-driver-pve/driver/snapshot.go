@@ -0,0 +1,140 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/rancher/machine/libmachine/log"
+)
+
+// Name of the pseudo-snapshot representing the current state of a Proxmox VE virtual machine.
+const pveCurrentSnapshotName = "current"
+
+// Maximum length of a Proxmox VE snapshot name.
+const pveSnapshotNameMaxLength = 40
+
+// Regexp for Proxmox VE snapshot name.
+var snapshotNameRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]+$`)
+
+// Checks whether a given name is a valid Proxmox VE snapshot name.
+func checkSnapshotName(name string) error {
+	if !snapshotNameRegexp.MatchString(name) || len(name) > pveSnapshotNameMaxLength {
+		return fmt.Errorf(
+			"snapshot name '%s' must start with a letter, contain only letters, digits, '_' or '-', and be 2-%d characters long",
+			name,
+			pveSnapshotNameMaxLength,
+		)
+	}
+
+	if name == pveCurrentSnapshotName {
+		return fmt.Errorf("snapshot name '%s' is reserved", name)
+	}
+
+	return nil
+}
+
+// CreateSnapshot creates a snapshot of the current machine, including its RAM state if withRAM is set.
+func (d *Driver) CreateSnapshot(ctx context.Context, name, description string, withRAM bool) error {
+	if err := checkSnapshotName(name); err != nil {
+		return err
+	}
+
+	log.Infof("Creating snapshot '%s' of the machine...", name)
+
+	err := d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+		if withRAM && !vm.IsRunning() {
+			return nil, errors.New("RAM state can be saved only while the machine is running")
+		}
+
+		options := map[string]interface{}{
+			"snapname":    name,
+			"description": description,
+		}
+
+		if withRAM {
+			options["vmstate"] = 1
+		}
+
+		var upid proxmox.UPID
+
+		if err := d.getPVEClient().Post(ctx, fmt.Sprintf("/nodes/%s/qemu/%d/snapshot", vm.Node, vm.VMID), options, &upid); err != nil {
+			return nil, err
+		}
+
+		return proxmox.NewTask(upid, d.getPVEClient()), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot '%s': %w", name, err)
+	}
+
+	return nil
+}
+
+// ListSnapshots returns snapshots of the current machine.
+func (d *Driver) ListSnapshots(ctx context.Context) ([]*proxmox.Snapshot, error) {
+	machine, err := d.getCurrentMachine(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots, err := machine.Snapshots(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve snapshots of Proxmox VE virtual machine ID='%d': %w", machine.VMID, err)
+	}
+
+	result := make([]*proxmox.Snapshot, 0, len(snapshots))
+
+	for _, snapshot := range snapshots {
+		if snapshot.Name != pveCurrentSnapshotName {
+			result = append(result, snapshot)
+		}
+	}
+
+	return result, nil
+}
+
+// RollbackSnapshot rolls the current machine back to a given snapshot.
+// Machine is running afterwards only if the snapshot includes RAM state.
+func (d *Driver) RollbackSnapshot(ctx context.Context, name string) error {
+	if err := checkSnapshotName(name); err != nil {
+		return err
+	}
+
+	log.Infof("Rolling the machine back to snapshot '%s'...", name)
+
+	err := d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+		return vm.SnapshotRollback(ctx, name)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to roll back to snapshot '%s': %w", name, err)
+	}
+
+	return nil
+}
+
+// DeleteSnapshot deletes a given snapshot of the current machine.
+func (d *Driver) DeleteSnapshot(ctx context.Context, name string) error {
+	if err := checkSnapshotName(name); err != nil {
+		return err
+	}
+
+	log.Infof("Deleting snapshot '%s' of the machine...", name)
+
+	err := d.runTaskOnCurrentMachine(ctx, func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+		var upid proxmox.UPID
+
+		if err := d.getPVEClient().Delete(ctx, fmt.Sprintf("/nodes/%s/qemu/%d/snapshot/%s", vm.Node, vm.VMID, name), &upid); err != nil {
+			return nil, err
+		}
+
+		return proxmox.NewTask(upid, d.getPVEClient()), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot '%s': %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package driver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_checkSnapshotName(t *testing.T) {
+	for _, name := range []string{"initial", "before-upgrade_2", "a1", "A" + strings.Repeat("b", 39)} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, checkSnapshotName(name))
+		})
+	}
+
+	for _, name := range []string{"", "a", "1st", "-a", "before upgrade", "a.b", "current", "A" + strings.Repeat("b", 40)} {
+		t.Run(name, func(t *testing.T) {
+			require.Error(t, checkSnapshotName(name))
+		})
+	}
+}
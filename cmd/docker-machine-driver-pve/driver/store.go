@@ -0,0 +1,46 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Name of the file with machine's config in its directory of the machine store.
+const machineConfigFileName = "config.json"
+
+// LoadDriver loads the driver of a machine from its directory in the machine store (e.g. '~/.docker/machine/machines/<name>').
+func LoadDriver(machineDir string) (*Driver, error) {
+	path := filepath.Join(machineDir, machineConfigFileName)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read machine config '%s': %w", path, err)
+	}
+
+	machineConfig := struct {
+		DriverName string
+		Driver     json.RawMessage
+	}{}
+
+	if err := json.Unmarshal(content, &machineConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse machine config '%s': %w", path, err)
+	}
+
+	d := NewDriver("", "")
+
+	if machineConfig.DriverName != d.DriverName() {
+		return nil, fmt.Errorf("machine config '%s' belongs to driver '%s', not '%s'", path, machineConfig.DriverName, d.DriverName())
+	}
+
+	if err := json.Unmarshal(machineConfig.Driver, d); err != nil {
+		return nil, fmt.Errorf("failed to parse driver config in machine config '%s': %w", path, err)
+	}
+
+	if d.PVEMachineID == nil {
+		return nil, fmt.Errorf("machine config '%s' has no Proxmox VE virtual machine ID, the machine might not have been created", path)
+	}
+
+	return d, nil
+}
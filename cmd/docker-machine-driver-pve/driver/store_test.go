@@ -0,0 +1,39 @@
+package driver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LoadDriver(t *testing.T) {
+	machineDir := t.TempDir()
+
+	config := `{"ConfigVersion": 3, "DriverName": "pve", "Driver": {"MachineName": "machine", "StorePath": "/store", "URL": "https://pve:8006", "ResourcePoolName": "pool", "PVEMachineID": 5000}}`
+	require.NoError(t, os.WriteFile(filepath.Join(machineDir, machineConfigFileName), []byte(config), 0o600))
+
+	d, err := LoadDriver(machineDir)
+	require.NoError(t, err)
+	require.Equal(t, "machine", d.MachineName)
+	require.Equal(t, "https://pve:8006", d.URL)
+	require.Equal(t, "pool", d.ResourcePoolName)
+	require.Equal(t, 5000, *d.PVEMachineID)
+
+	for name, config := range map[string]string{
+		"other driver": `{"DriverName": "virtualbox", "Driver": {"PVEMachineID": 5000}}`,
+		"no machine":   `{"DriverName": "pve", "Driver": {"MachineName": "machine"}}`,
+		"invalid":      `{"DriverName": `,
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(filepath.Join(machineDir, machineConfigFileName), []byte(config), 0o600))
+
+			_, err := LoadDriver(machineDir)
+			require.Error(t, err)
+		})
+	}
+
+	_, err = LoadDriver(t.TempDir())
+	require.Error(t, err)
+}
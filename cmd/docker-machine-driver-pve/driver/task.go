@@ -0,0 +1,129 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/rancher/machine/libmachine/log"
+)
+
+const (
+	// Number of log lines of a failed Proxmox VE task to retrieve at once.
+	pveTaskLogPageLines = 50
+
+	// Number of last log lines of a failed Proxmox VE task to keep in the error.
+	pveTaskLogTailLines = 10
+)
+
+// TaskError is returned when a Proxmox VE task finishes unsuccessfully.
+type TaskError struct {
+	// Unique ID of the task.
+	UPID string
+
+	// Name of the node that ran the task.
+	Node string
+
+	// Type of the task (e.g. 'qmclone').
+	Type string
+
+	// Exit status of the task, i.e. the error message for failed tasks.
+	ExitStatus string
+
+	// Last lines of the task log.
+	Log []string
+}
+
+// Error implements error.
+func (e *TaskError) Error() string {
+	message := fmt.Sprintf("task ID='%s' of type '%s' on node '%s' failed with exit status '%s'", e.UPID, e.Type, e.Node, e.ExitStatus)
+
+	if len(e.Log) > 0 {
+		message += "; last log lines: " + strings.Join(e.Log, "; ")
+	}
+
+	return message
+}
+
+// Returns whether a given cause appears in the exit status or log of the task.
+func (e *TaskError) HasCause(cause string) bool {
+	if strings.Contains(e.ExitStatus, cause) {
+		return true
+	}
+
+	return slices.ContainsFunc(e.Log, func(line string) bool {
+		return strings.Contains(line, cause)
+	})
+}
+
+// Returns error describing a failed Proxmox VE task, including the tail of its log.
+// Log is left empty if it cannot be retrieved.
+func (d *Driver) newTaskError(ctx context.Context, task *proxmox.Task) *TaskError {
+	taskError := &TaskError{
+		UPID:       string(task.UPID),
+		Node:       task.Node,
+		Type:       task.Type,
+		ExitStatus: task.ExitStatus,
+	}
+
+	taskLog, err := d.getTaskLogLastPage(ctx, task)
+	if err != nil {
+		log.Debugf("Failed to retrieve log of task ID='%s': %s", task.UPID, err.Error())
+		return taskError
+	}
+
+	taskError.Log = getTaskLogTail(taskLog, pveTaskLogTailLines)
+
+	return taskError
+}
+
+// Retrieves the last page of a task log, without retrieving the whole log.
+// Client does not expose the total number of lines, so the first page is retrieved directly.
+func (d *Driver) getTaskLogLastPage(ctx context.Context, task *proxmox.Task) (proxmox.Log, error) {
+	firstPage := struct {
+		Data []struct {
+			N int    `json:"n"`
+			T string `json:"t"`
+		} `json:"data"`
+		Total int `json:"total"`
+	}{}
+
+	path := fmt.Sprintf("/nodes/%s/tasks/%s/log?start=0&limit=%d", url.PathEscape(task.Node), url.PathEscape(string(task.UPID)), pveTaskLogPageLines)
+	if err := d.getPVERaw(ctx, path, &firstPage); err != nil {
+		return nil, err
+	}
+
+	if firstPage.Total > len(firstPage.Data) {
+		return task.Log(ctx, max(firstPage.Total-pveTaskLogPageLines, 0), pveTaskLogPageLines)
+	}
+
+	taskLog := proxmox.Log{}
+	for _, line := range firstPage.Data {
+		taskLog[line.N-1] = line.T
+	}
+
+	return taskLog, nil
+}
+
+// Returns at most a given number of last non-empty lines of a task log.
+func getTaskLogTail(taskLog proxmox.Log, count int) []string {
+	lines := make([]string, 0, count)
+
+	for _, index := range slices.Backward(slices.Sorted(maps.Keys(taskLog))) {
+		if len(lines) == count {
+			break
+		}
+
+		if line := strings.TrimSpace(taskLog[index]); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	slices.Reverse(lines)
+
+	return lines
+}
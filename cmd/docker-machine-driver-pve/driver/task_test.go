@@ -0,0 +1,87 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getTaskLogTail(t *testing.T) {
+	taskLog := proxmox.Log{
+		0: "create full clone of drive scsi0 (local-lvm:base-100-disk-0)",
+		1: "transferred 0.0 B of 200.0 GiB (0.00%)",
+		2: "",
+		3: "qemu-img: error while writing at byte 0: No space left on device",
+		4: "TASK ERROR: clone failed: copy failed: command '/usr/bin/qemu-img convert' failed: exit code 1",
+	}
+
+	require.Equal(t, []string{
+		"qemu-img: error while writing at byte 0: No space left on device",
+		"TASK ERROR: clone failed: copy failed: command '/usr/bin/qemu-img convert' failed: exit code 1",
+	}, getTaskLogTail(taskLog, 2))
+	require.Len(t, getTaskLogTail(taskLog, 10), 4)
+	require.Empty(t, getTaskLogTail(proxmox.Log{}, 10))
+}
+
+func Test_getTaskLogLastPage(t *testing.T) {
+	const total = 120
+
+	requestedStarts := []string{}
+
+	// Serves '/nodes/{node}/tasks/{upid}/log' of a task with a given number of lines
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "PVEAPIToken=root@pam!test=secret", r.Header.Get("Authorization"))
+
+		requestedStarts = append(requestedStarts, r.URL.Query().Get("start"))
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		lines := []map[string]interface{}{}
+		for n := start + 1; n <= min(start+limit, total); n++ {
+			lines = append(lines, map[string]interface{}{"n": n, "t": fmt.Sprintf("line %d", n)})
+		}
+
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"data": lines, "total": total}))
+	}))
+	defer server.Close()
+
+	d := NewDriver("machine", "")
+	d.URL = server.URL
+	d.TokenID = "root@pam!test"
+	d.TokenSecret = "secret"
+
+	task := proxmox.NewTask("UPID:pve1:0000A1B2:0001C3D4:65000000:qmclone:100:root@pam:", d.getPVEClient())
+
+	taskLog, err := d.getTaskLogLastPage(context.Background(), task)
+	require.NoError(t, err)
+	require.Equal(t, []string{"0", strconv.Itoa(total - pveTaskLogPageLines)}, requestedStarts)
+	require.Equal(t, []string{"line 119", "line 120"}, getTaskLogTail(taskLog, 2))
+	require.Len(t, taskLog, pveTaskLogPageLines)
+}
+
+func Test_TaskError(t *testing.T) {
+	taskError := &TaskError{
+		UPID:       "UPID:pve1:0000A1B2:0001C3D4:65000000:qmclone:100:root@pam:",
+		Node:       "pve1",
+		Type:       "qmclone",
+		ExitStatus: "unable to create VM 5000: config file already exists",
+		Log:        []string{"trying to acquire lock...", "TASK ERROR: can't lock file '/var/lock/qemu-server/lock-5000.conf' - got timeout"},
+	}
+
+	require.Equal(
+		t,
+		"task ID='UPID:pve1:0000A1B2:0001C3D4:65000000:qmclone:100:root@pam:' of type 'qmclone' on node 'pve1' failed with exit status 'unable to create VM 5000: config file already exists'; "+
+			"last log lines: trying to acquire lock...; TASK ERROR: can't lock file '/var/lock/qemu-server/lock-5000.conf' - got timeout",
+		taskError.Error(),
+	)
+	require.True(t, taskError.HasCause("config file already exists"))
+	require.True(t, taskError.HasCause("got timeout"))
+	require.False(t, taskError.HasCause("No space left on device"))
+}
@@ -0,0 +1,140 @@
+package driver
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/rancher/machine/libmachine/log"
+)
+
+// Prefix of the template selector matching templates by tag.
+const templateSelectorTagPrefix = "tag:"
+
+// Tag carrying version of a template (e.g. 'v1.2.3', '20240101').
+var templateVersionTagRegexp = regexp.MustCompile(`^v?(\d+(?:\.\d+)*)$`)
+
+// Template matching a selector.
+type templateCandidate struct {
+	// ID of the template.
+	VMID int
+
+	// Creation time of the template as Unix timestamp, 0 if unknown.
+	Ctime int64
+
+	// Version parsed from the version tag, nil if the template has no version tag.
+	Version []int
+}
+
+// Resolves the current template selector to ID of the newest matching template in the resource pool.
+func (d *Driver) resolvePVETemplateSelector(ctx context.Context) (int, error) {
+	resourcePool, err := d.getCurrentPVEResourcePool(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	tag, byTag := strings.CutPrefix(d.TemplateSelector, templateSelectorTagPrefix)
+
+	candidates := make([]templateCandidate, 0)
+
+	for _, member := range resourcePool.Members {
+		if member.Type != "qemu" || member.Template != 1 || member.VMID > math.MaxInt {
+			continue
+		}
+
+		if !byTag && member.Name != d.TemplateSelector {
+			continue
+		}
+
+		template, err := d.getPVEVirtualMachineOnNode(ctx, int(member.VMID), member.Node)
+		if err != nil {
+			return -1, err
+		}
+
+		if byTag && !template.HasTag(tag) {
+			continue
+		}
+
+		candidates = append(candidates, newTemplateCandidate(template))
+	}
+
+	if len(candidates) == 0 {
+		return -1, fmt.Errorf("no template matching '%s' found in resource pool name='%s'", d.TemplateSelector, d.ResourcePoolName)
+	}
+
+	newest := slices.MaxFunc(candidates, compareTemplateCandidates)
+
+	log.Infof("Resolved template '%s' to ID='%d' (newest of %d matching template(s))", d.TemplateSelector, newest.VMID, len(candidates))
+
+	return newest.VMID, nil
+}
+
+// Creates a template candidate from Proxmox VE template.
+func newTemplateCandidate(template *proxmox.VirtualMachine) templateCandidate {
+	candidate := templateCandidate{
+		VMID: int(template.VMID), //nolint:gosec
+	}
+
+	if template.VirtualMachineConfig == nil {
+		return candidate
+	}
+
+	candidate.Ctime, _ = strconv.ParseInt(getParamFromPveDevice(template.VirtualMachineConfig.Meta, "ctime"), 10, 64)
+
+	for _, tag := range splitPVETags(template.VirtualMachineConfig.Tags) {
+		if version := parseTemplateVersion(tag); version != nil && slices.Compare(version, candidate.Version) > 0 {
+			candidate.Version = version
+		}
+	}
+
+	return candidate
+}
+
+// Compares template candidates by version tag, then by creation time and finally by ID.
+// Candidates without version tag are older than any candidate with one, so the order is transitive.
+func compareTemplateCandidates(a, b templateCandidate) int {
+	if a.Version == nil && b.Version != nil {
+		return -1
+	}
+
+	if a.Version != nil && b.Version == nil {
+		return 1
+	}
+
+	if result := slices.Compare(a.Version, b.Version); result != 0 {
+		return result
+	}
+
+	if result := cmp.Compare(a.Ctime, b.Ctime); result != 0 {
+		return result
+	}
+
+	return cmp.Compare(a.VMID, b.VMID)
+}
+
+// Parses version from a template tag (e.g. 'v1.2.3'). Returns nil if the tag is not a version tag.
+func parseTemplateVersion(tag string) []int {
+	matches := templateVersionTagRegexp.FindStringSubmatch(tag)
+	if matches == nil {
+		return nil
+	}
+
+	version := []int{}
+
+	for _, part := range strings.Split(matches[1], ".") {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return nil
+		}
+
+		version = append(version, number)
+	}
+
+	return version
+}
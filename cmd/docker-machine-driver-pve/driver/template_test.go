@@ -0,0 +1,95 @@
+package driver
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseTemplateVersion(t *testing.T) {
+	tests := map[string][]int{
+		"v1.2.3":         {1, 2, 3},
+		"20240101":       {20240101},
+		"2.10":           {2, 10},
+		"ubuntu-24.04":   nil,
+		"docker-machine": nil,
+		"v":              nil,
+	}
+
+	for tag, expectedVersion := range tests {
+		t.Run(tag, func(t *testing.T) {
+			require.Equal(t, expectedVersion, parseTemplateVersion(tag))
+		})
+	}
+}
+
+func Test_compareTemplateCandidates(t *testing.T) {
+	tests := map[string]struct {
+		candidates   []templateCandidate
+		expectedVMID int
+	}{
+		"version tag wins over creation time": {
+			candidates: []templateCandidate{
+				{VMID: 100, Ctime: 2000, Version: []int{1, 9}},
+				{VMID: 101, Ctime: 1000, Version: []int{1, 10}},
+			},
+			expectedVMID: 101,
+		},
+		"creation time without version tags": {
+			candidates: []templateCandidate{
+				{VMID: 101, Ctime: 1000},
+				{VMID: 100, Ctime: 2000},
+			},
+			expectedVMID: 100,
+		},
+		"version tag wins over newer template without version tag": {
+			candidates: []templateCandidate{
+				{VMID: 100, Ctime: 2000},
+				{VMID: 101, Ctime: 1000, Version: []int{3}},
+			},
+			expectedVMID: 101,
+		},
+		"ID as last resort": {
+			candidates: []templateCandidate{
+				{VMID: 101},
+				{VMID: 100},
+			},
+			expectedVMID: 101,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, test.expectedVMID, slices.MaxFunc(test.candidates, compareTemplateCandidates).VMID)
+		})
+	}
+
+	t.Run("mixed candidates in any order", func(t *testing.T) {
+		candidates := []templateCandidate{
+			{VMID: 100, Ctime: 3000},
+			{VMID: 101, Ctime: 1000, Version: []int{2}},
+			{VMID: 102, Ctime: 2000, Version: []int{1}},
+			{VMID: 103, Ctime: 4000},
+		}
+
+		// Every rotation and its reverse must sort the same way
+		for shift := range candidates {
+			for _, reversed := range []bool{false, true} {
+				shuffled := append(slices.Clone(candidates[shift:]), candidates[:shift]...)
+				if reversed {
+					slices.Reverse(shuffled)
+				}
+
+				slices.SortFunc(shuffled, compareTemplateCandidates)
+
+				vmids := []int{}
+				for _, candidate := range shuffled {
+					vmids = append(vmids, candidate.VMID)
+				}
+
+				require.Equal(t, []int{100, 103, 102, 101}, vmids)
+			}
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Key of a template variable, usable as '{{ .Vars.key }}'.
+var templateVarKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Data available to cloud-init userdata and metadata templates.
+type cloudinitTemplateData struct {
+	// Name of the machine.
+	MachineName string
+
+	// ID of the machine, 0 until the machine is created.
+	VMID int
+
+	// Name of the node the machine runs on, empty until the machine is created unless target node is set.
+	Node string
+
+	// Name of the resource pool of the machine.
+	ResourcePool string
+
+	// Username of the SSH user created via cloud-init.
+	SSHUser string
+
+	// Static IP address allocated to the machine, empty if not allocated (yet).
+	IPAddress string
+
+	// Values of '--pve-template-var' flags.
+	Vars map[string]string
+}
+
+// Loads cloud-init document template from a file, or uses the value as inline template if no such file exists.
+func loadCloudinitTemplate(value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", nil
+	}
+
+	if fileInfo, err := os.Stat(value); err == nil && !fileInfo.IsDir() {
+		content, err := os.ReadFile(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file '%s': %w", value, err)
+		}
+
+		value = string(content)
+	}
+
+	if _, err := template.New("").Parse(value); err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	return value, nil
+}
+
+// Returns data for cloud-init templates of the current machine.
+func (d *Driver) getCloudinitTemplateData(nodeName string) cloudinitTemplateData {
+	data := cloudinitTemplateData{
+		MachineName:  d.MachineName,
+		Node:         nodeName,
+		ResourcePool: d.ResourcePoolName,
+		SSHUser:      d.SSHUser,
+		IPAddress:    d.IPAddress,
+		Vars:         d.TemplateVars,
+	}
+
+	if d.PVEMachineID != nil {
+		data.VMID = *d.PVEMachineID
+	}
+
+	if data.Vars == nil {
+		data.Vars = map[string]string{}
+	}
+
+	return data
+}
+
+// Renders cloud-init document template, referencing unknown template variables is an error.
+func renderCloudinitTemplate(name, content string, data cloudinitTemplateData) (string, error) {
+	documentTemplate, err := template.New(name).Option("missingkey=error").Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+
+	var document strings.Builder
+
+	if err := documentTemplate.Execute(&document, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", name, err)
+	}
+
+	return document.String(), nil
+}
+
+// Checks that cloud-init templates render to valid documents, before the machine is created.
+func (d *Driver) checkCloudinitTemplates() error {
+	if d.CloudinitUserdata == "" && d.CloudinitMetadata == "" {
+		return nil
+	}
+
+	data := d.getCloudinitTemplateData(d.TargetNodeName)
+
+	if _, err := d.generateCloudinitMetadata(data); err != nil {
+		return fmt.Errorf("failed to generate cloud-init metadata: %w", err)
+	}
+
+	if _, err := d.generateCloudinitUserdata(data); err != nil {
+		return fmt.Errorf("failed to generate cloud-init userdata: %w", err)
+	}
+
+	return nil
+}
+
+// Parses template variable (e.g. 'domain=example.com').
+func parseTemplateVar(value string) (string, string, error) {
+	key, varValue, found := strings.Cut(value, "=")
+	if !found {
+		return "", "", errors.New("variable must be in format 'key=value'")
+	}
+
+	key = strings.TrimSpace(key)
+	if !templateVarKeyRegexp.MatchString(key) {
+		return "", "", fmt.Errorf("key '%s' may only contain letters, digits and '_' and must not start with a digit", key)
+	}
+
+	return key, varValue, nil
+}
+
+// Parses cloud-init document (userdata in cloud-config format or metadata) to a map.
+func parseCloudinitDocument(document string) (map[string]interface{}, error) {
+	parsedDocument := map[string]interface{}{}
+
+	if err := yaml.Unmarshal([]byte(document), &parsedDocument); err != nil {
+		return nil, fmt.Errorf("document is not a valid YAML mapping: %w", err)
+	}
+
+	return parsedDocument, nil
+}
+
+// Deep-merges user-supplied cloud-init userdata into the userdata generated by the driver.
+// Maps are merged and lists are appended, so entries generated by the driver (e.g. 'users[0]') are kept first.
+// Returns an error naming the offending key if a value generated by the driver would be overwritten.
+func mergeCloudinitUserdata(driverUserdata, userUserdata map[string]interface{}, sshUser string) error {
+	if err := mergeCloudinitValues(driverUserdata, userUserdata, ""); err != nil {
+		return err
+	}
+
+	// Driver's user must stay the only one with its name, otherwise cloud-init would reconfigure it
+	users, _ := driverUserdata["users"].([]interface{})
+
+	for index, user := range users {
+		if index == 0 {
+			continue
+		}
+
+		if userMap, ok := user.(map[string]interface{}); ok && userMap["name"] == sshUser {
+			return fmt.Errorf("conflicting key 'users[%d]': user '%s' is managed by the driver", index, sshUser)
+		}
+	}
+
+	return nil
+}
+
+// Deep-merges values of a source map into a destination map.
+func mergeCloudinitValues(destination, source map[string]interface{}, path string) error {
+	for key, sourceValue := range source {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+
+		destinationValue, found := destination[key]
+		if !found {
+			destination[key] = sourceValue
+			continue
+		}
+
+		switch typedDestinationValue := destinationValue.(type) {
+		case map[string]interface{}:
+			typedSourceValue, ok := sourceValue.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("conflicting key '%s': expected a mapping", keyPath)
+			}
+
+			if err := mergeCloudinitValues(typedDestinationValue, typedSourceValue, keyPath); err != nil {
+				return err
+			}
+		case []interface{}:
+			typedSourceValue, ok := sourceValue.([]interface{})
+			if !ok {
+				return fmt.Errorf("conflicting key '%s': expected a list", keyPath)
+			}
+
+			destination[key] = append(typedDestinationValue, typedSourceValue...)
+		default:
+			if !reflect.DeepEqual(destinationValue, sourceValue) {
+				return fmt.Errorf("conflicting key '%s': value %v is managed by the driver", keyPath, destinationValue)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validates final cloud-init userdata before it is passed to the machine.
+func validateCloudinitUserdata(userdata string) error {
+	if !strings.HasPrefix(userdata, "#cloud-config\n") {
+		return errors.New("userdata must start with '#cloud-config'")
+	}
+
+	parsedUserdata, err := parseCloudinitDocument(userdata)
+	if err != nil {
+		return err
+	}
+
+	users, ok := parsedUserdata["users"].([]interface{})
+	if !ok || len(users) == 0 {
+		return errors.New("userdata must contain list 'users'")
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package driver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_loadCloudinitTemplate(t *testing.T) {
+	inlineUserdata := "packages:\n  - htop\n"
+
+	path := filepath.Join(t.TempDir(), "userdata.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(inlineUserdata), 0o600))
+
+	userdata, err := loadCloudinitTemplate(path)
+	require.NoError(t, err)
+	require.Equal(t, inlineUserdata, userdata)
+
+	userdata, err = loadCloudinitTemplate(inlineUserdata)
+	require.NoError(t, err)
+	require.Equal(t, inlineUserdata, userdata)
+
+	userdata, err = loadCloudinitTemplate("")
+	require.NoError(t, err)
+	require.Empty(t, userdata)
+
+	for _, value := range []string{"hostname: {{ .MachineName", "{{ end }}"} {
+		t.Run(value, func(t *testing.T) {
+			_, err := loadCloudinitTemplate(value)
+			require.Error(t, err)
+		})
+	}
+}
+
+func Test_renderCloudinitTemplate(t *testing.T) {
+	d := NewDriver("machine", "")
+	d.PVEMachineID = new(int)
+	*d.PVEMachineID = 5000
+	d.ResourcePoolName = "rancher"
+	d.SSHUser = "service"
+	d.IPAddress = "10.20.0.10"
+	d.TemplateVars = map[string]string{"domain": "example.com"}
+
+	document, err := renderCloudinitTemplate(
+		"userdata",
+		"fqdn: {{ .MachineName }}.{{ .Vars.domain }}\nid: {{ .VMID }}@{{ .Node }}/{{ .ResourcePool }} {{ .SSHUser }} {{ .IPAddress }}\n",
+		d.getCloudinitTemplateData("pve1"),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "fqdn: machine.example.com\nid: 5000@pve1/rancher service 10.20.0.10\n", document)
+
+	_, err = renderCloudinitTemplate("userdata", "fqdn: {{ .Vars.missing }}", d.getCloudinitTemplateData("pve1"))
+	require.Error(t, err)
+}
+
+func Test_parseTemplateVar(t *testing.T) {
+	key, value, err := parseTemplateVar("domain=example.com=x")
+	require.NoError(t, err)
+	require.Equal(t, "domain", key)
+	require.Equal(t, "example.com=x", value)
+
+	for _, value := range []string{"domain", "=example.com", "my-domain=example.com", "1domain=example.com"} {
+		t.Run(value, func(t *testing.T) {
+			_, _, err := parseTemplateVar(value)
+			require.Error(t, err)
+		})
+	}
+}
+
+func Test_mergeCloudinitUserdata(t *testing.T) {
+	driverUserdata, err := parseCloudinitDocument(`
+hostname: machine
+users:
+  - name: service
+    sudo: ALL=(ALL) NOPASSWD:ALL
+growpart:
+  mode: auto
+  devices: [/]
+`)
+	require.NoError(t, err)
+
+	userUserdata, err := parseCloudinitDocument(`
+hostname: machine
+users:
+  - name: backup
+growpart:
+  devices: [/var]
+  ignore_growroot_disabled: true
+packages: [htop]
+`)
+	require.NoError(t, err)
+
+	require.NoError(t, mergeCloudinitUserdata(driverUserdata, userUserdata, "service"))
+	require.Equal(t, map[string]interface{}{
+		"hostname": "machine",
+		"users": []interface{}{
+			map[string]interface{}{"name": "service", "sudo": "ALL=(ALL) NOPASSWD:ALL"},
+			map[string]interface{}{"name": "backup"},
+		},
+		"growpart": map[string]interface{}{
+			"mode":                     "auto",
+			"devices":                  []interface{}{"/", "/var"},
+			"ignore_growroot_disabled": true,
+		},
+		"packages": []interface{}{"htop"},
+	}, driverUserdata)
+}
+
+func Test_mergeCloudinitUserdata_conflicts(t *testing.T) {
+	tests := map[string]string{
+		"hostname: other":                    "'hostname'",
+		"growpart: {mode: 'off'}":            "'growpart.mode'",
+		"growpart: [/]":                      "'growpart'",
+		"users: {name: backup}":              "'users'",
+		"users: [{name: service, sudo: ''}]": "'users[1]'",
+	}
+
+	for userdata, expectedKey := range tests {
+		t.Run(userdata, func(t *testing.T) {
+			driverUserdata, err := parseCloudinitDocument("{hostname: machine, users: [{name: service}], growpart: {mode: auto}}")
+			require.NoError(t, err)
+
+			userUserdata, err := parseCloudinitDocument(userdata)
+			require.NoError(t, err)
+
+			err = mergeCloudinitUserdata(driverUserdata, userUserdata, "service")
+			require.Error(t, err)
+			require.Contains(t, err.Error(), expectedKey)
+		})
+	}
+}
+
+func Test_generateCloudinitUserdata(t *testing.T) {
+	d := NewDriver("machine", t.TempDir())
+	d.SSHUser = "service"
+	d.CloudinitUserdata = "runcmd:\n  - echo hello\n"
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(d.GetSSHPublicKeyPath()), 0o700))
+	require.NoError(t, os.WriteFile(d.GetSSHPublicKeyPath(), []byte("ssh-ed25519 AAAA"), 0o600))
+
+	userdata, err := d.generateCloudinitUserdata(d.getCloudinitTemplateData(""))
+	require.NoError(t, err)
+	require.NoError(t, validateCloudinitUserdata(userdata))
+
+	parsedUserdata, err := parseCloudinitDocument(userdata)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"echo hello"}, parsedUserdata["runcmd"])
+	require.Equal(t, "service", parsedUserdata["users"].([]interface{})[0].(map[string]interface{})["name"])
+
+	d.CloudinitUserdata = "preserve_hostname: true\n"
+
+	_, err = d.generateCloudinitUserdata(d.getCloudinitTemplateData(""))
+	require.ErrorContains(t, err, "'preserve_hostname'")
+
+	for _, userdata := range []string{"- htop", "packages: [htop", "packages: [{{ .Vars.missing }}]"} {
+		t.Run(userdata, func(t *testing.T) {
+			d.CloudinitUserdata = userdata
+
+			require.Error(t, d.checkCloudinitTemplates())
+		})
+	}
+}
+
+func Test_generateCloudinitMetadata(t *testing.T) {
+	d := NewDriver("machine", "")
+	d.TemplateVars = map[string]string{"domain": "example.com"}
+	d.CloudinitMetadata = "local-hostname: {{ .MachineName }}.{{ .Vars.domain }}\n"
+
+	metadata, err := d.generateCloudinitMetadata(d.getCloudinitTemplateData(""))
+	require.NoError(t, err)
+
+	parsedMetadata, err := parseCloudinitDocument(metadata)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"instance-id":    "machine",
+		"hostname":       "machine",
+		"local-hostname": "machine.example.com",
+	}, parsedMetadata)
+
+	d.CloudinitMetadata = "instance-id: other"
+
+	_, err = d.generateCloudinitMetadata(d.getCloudinitTemplateData(""))
+	require.ErrorContains(t, err, "'instance-id'")
+}
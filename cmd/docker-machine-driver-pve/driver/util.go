@@ -1,10 +1,14 @@
 package driver
 
 import (
+	"regexp"
 	"slices"
 	"strings"
 )
 
+// Characters allowed in Proxmox VE tags.
+var pveTagRegexp = regexp.MustCompile(`^[a-z0-9_][a-z0-9_\-+.]*$`)
+
 func getMACFromPveNetworkDevice(device string) string {
 	models := []string{
 		"e1000",
@@ -39,3 +43,32 @@ func getMACFromPveNetworkDevice(device string) string {
 
 	return ""
 }
+
+// Returns value of a parameter from Proxmox VE device configuration (e.g. 'size' from 'local:vm-100-disk-0,size=32G').
+func getParamFromPveDevice(device, name string) string {
+	for _, param := range strings.Split(device, ",") {
+		//nolint:mnd
+		values := strings.SplitN(param, "=", 2)
+
+		//nolint:mnd
+		if len(values) == 2 && values[0] == name {
+			return values[1]
+		}
+	}
+
+	return ""
+}
+
+// Checks whether a Proxmox VE tag list (e.g. 'docker-machine;cloud-init') contains a given tag.
+func hasPVETag(tags, tag string) bool {
+	return slices.ContainsFunc(splitPVETags(tags), func(value string) bool {
+		return value == tag
+	})
+}
+
+// Splits a Proxmox VE tag list into separate tags.
+func splitPVETags(tags string) []string {
+	return strings.FieldsFunc(tags, func(r rune) bool {
+		return r == ';' || r == ',' || r == ' '
+	})
+}
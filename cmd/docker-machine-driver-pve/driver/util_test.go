@@ -31,3 +31,10 @@ func Test_getMACFromPveNetworkDevice(t *testing.T) {
 		)
 	}
 }
+
+func Test_hasPVETag(t *testing.T) {
+	require.True(t, hasPVETag("docker-machine", "docker-machine"))
+	require.True(t, hasPVETag("cloud-init;docker-machine", "docker-machine"))
+	require.False(t, hasPVETag("docker-machine-old", "docker-machine"))
+	require.False(t, hasPVETag("", "docker-machine"))
+}
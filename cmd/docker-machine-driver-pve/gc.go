@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rancher/machine/libmachine/log"
+	"github.com/rancher/machine/libmachine/mcnflag"
+	"github.com/stellatarum/docker-machine-driver-pve/cmd/docker-machine-driver-pve/driver"
+)
+
+// Usage of the gc command, excluding connection flags.
+const gcUsage = "--store-path <path> | --names <name,...> [--min-age <duration>] [--yes]"
+
+// Default minimum age of an orphaned machine to be removed.
+const gcDefaultMinAge = time.Hour
+
+// Reports orphaned machines in the resource pool, or removes them.
+//
+//nolint:cyclop
+func runGCCommand(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+
+	// Connection flags default to environment variables of the driver flags, read after parsing to keep secrets out of usage
+	envVars := map[string]string{}
+
+	for _, connectionFlag := range driver.ConnectionFlags() {
+		switch connectionFlag := connectionFlag.(type) {
+		case mcnflag.StringFlag:
+			flags.String(connectionFlag.Name, connectionFlag.Value, connectionFlag.Usage)
+			envVars[connectionFlag.Name] = connectionFlag.EnvVar
+		case mcnflag.BoolFlag:
+			flags.Bool(connectionFlag.Name, false, connectionFlag.Usage)
+			envVars[connectionFlag.Name] = connectionFlag.EnvVar
+		}
+	}
+
+	storePath := flags.String("store-path", "", "Path to the machine store (e.g. '~/.docker/machine'), machines with a directory in it are kept")
+	names := flags.String("names", "", "Comma-separated names of machines to keep, instead of or in addition to the machine store")
+	minAge := flags.Duration("min-age", gcDefaultMinAge, "Minimum age of an orphaned machine to be removed, protects machines being created")
+	yes := flags.Bool("yes", false, "Stop and remove orphaned machines instead of only reporting them")
+	debug := flags.Bool("debug", false, "Show debug logs")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return fmt.Errorf("gc expects no arguments after flags, got %d", flags.NArg())
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		delete(envVars, f.Name)
+	})
+
+	for name, envVar := range envVars {
+		if value := os.Getenv(envVar); value != "" {
+			if err := flags.Set(name, value); err != nil {
+				return fmt.Errorf("failed to parse environment variable '%s': %w", envVar, err)
+			}
+		}
+	}
+
+	if strings.TrimSpace(*storePath) == "" && strings.TrimSpace(*names) == "" {
+		return errors.New("flag '--store-path' or '--names' is required")
+	}
+
+	if *minAge < 0 {
+		return errors.New("flag '--min-age' must not be negative")
+	}
+
+	log.SetDebug(*debug)
+
+	knownNames := map[string]bool{}
+	knownIDs := map[int]bool{}
+
+	for _, name := range strings.Split(*names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			knownNames[name] = true
+		}
+	}
+
+	if strings.TrimSpace(*storePath) != "" {
+		if err := loadMachineStore(*storePath, knownNames, knownIDs); err != nil {
+			return err
+		}
+	}
+
+	d, err := driver.NewPoolDriver(flagSetOptions{flags: flags})
+	if err != nil {
+		return err
+	}
+
+	orphans, err := d.ListOrphanedMachines(ctx, knownNames, knownIDs)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint:mnd
+	removable := make([]*driver.OrphanedMachine, 0, len(orphans))
+
+	fmt.Fprintln(writer, "ID\tNAME\tNODE\tSTATUS\tAGE\tACTION")
+
+	for _, orphan := range orphans {
+		age, action := "unknown", "keep (age unknown)"
+
+		if !orphan.Created.IsZero() {
+			age = now.Sub(orphan.Created).Round(time.Second).String()
+			action = "keep (younger than --min-age)"
+
+			if now.Sub(orphan.Created) >= *minAge {
+				action = "remove"
+				removable = append(removable, orphan)
+			}
+		}
+
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%s\t%s\t%s\n", orphan.VMID, orphan.Name, orphan.Node, orphan.Status, age, action)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	if !*yes {
+		if len(removable) > 0 {
+			log.Infof("Found %d orphaned machine(s) to remove, run with '--yes' to remove them", len(removable))
+		}
+
+		return nil
+	}
+
+	errs := []error{}
+
+	for _, orphan := range removable {
+		log.Infof("Removing orphaned machine ID='%d' name='%s'...", orphan.VMID, orphan.Name)
+
+		if err := d.RemoveOrphanedMachine(ctx, orphan); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove orphaned machine ID='%d' name='%s': %w", orphan.VMID, orphan.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Records names and Proxmox VE IDs of machines in a machine store.
+// Machine store must exist, so that a mistyped path does not make all machines orphaned.
+func loadMachineStore(storePath string, names map[string]bool, ids map[int]bool) error {
+	machinesPath := filepath.Join(storePath, "machines")
+
+	entries, err := os.ReadDir(machinesPath)
+	if err != nil {
+		return fmt.Errorf("failed to read machine store '%s': %w", machinesPath, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		// Machine being created might not have its ID recorded yet, its name is enough to keep it
+		names[entry.Name()] = true
+
+		d, err := driver.LoadDriver(filepath.Join(machinesPath, entry.Name()))
+		if err != nil {
+			log.Debugf("Skipping ID of machine '%s': %s", entry.Name(), err.Error())
+			continue
+		}
+
+		ids[*d.PVEMachineID] = true
+	}
+
+	return nil
+}
+
+// Driver options backed by parsed flags.
+type flagSetOptions struct {
+	flags *flag.FlagSet
+}
+
+func (o flagSetOptions) String(key string) string {
+	if f := o.flags.Lookup(key); f != nil {
+		return f.Value.String()
+	}
+
+	return ""
+}
+
+func (o flagSetOptions) StringSlice(key string) []string {
+	if value := o.String(key); value != "" {
+		return strings.Split(value, ",")
+	}
+
+	return nil
+}
+
+func (o flagSetOptions) Int(key string) int {
+	value, _ := strconv.Atoi(o.String(key))
+	return value
+}
+
+func (o flagSetOptions) Bool(key string) bool {
+	value, _ := strconv.ParseBool(o.String(key))
+	return value
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime/debug"
+	"syscall"
 
 	"github.com/rancher/machine/libmachine/drivers/plugin"
 	"github.com/stellatarum/docker-machine-driver-pve/cmd/docker-machine-driver-pve/driver"
@@ -16,12 +19,22 @@ func main() {
 		showVersion = flag.Bool("version", false, "Show version information and exit")
 	)
 
+	// Operations in progress are interrupted on the first signal, the second one terminates the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
+
+	if len(os.Args) > 1 && runCommand(ctx, os.Args[1], os.Args[2:]) {
+		os.Exit(0)
+	}
+
 	flag.Parse()
 
 	switch {
 	case *showHelp:
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n\n", os.Args[0])
 		flag.PrintDefaults()
+		fmt.Fprint(os.Stderr, "\n")
+		printCommandsUsage()
 		os.Exit(0)
 	case *showVersion:
 		buildInfo, ok := debug.ReadBuildInfo()
@@ -33,6 +46,9 @@ func main() {
 		fmt.Fprint(os.Stderr, buildInfo.Main.Version+"\n")
 		os.Exit(0)
 	default:
-		plugin.RegisterDriver(driver.NewDriver("", ""))
+		d := driver.NewDriver("", "")
+		d.SetContext(ctx)
+
+		plugin.RegisterDriver(d)
 	}
 }
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// Migrates a machine to another node.
+func runMigrateCommand(ctx context.Context, args []string) error {
+	flags, machineFlags := newMachineFlagSet("migrate")
+	online := flags.Bool("online", false, "Migrate the running machine without stopping it")
+	targetStorage := flags.String("target-storage", "", "Storage on the target node for local disks of the machine")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("migrate expects target node as the only argument after flags, got %d arguments", flags.NArg())
+	}
+
+	d, err := machineFlags.loadDriver()
+	if err != nil {
+		return err
+	}
+
+	return d.Migrate(ctx, flags.Arg(0), *online, *targetStorage)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// Suspends a machine to RAM or disk.
+func runSuspendCommand(ctx context.Context, args []string) error {
+	flags, machineFlags := newMachineFlagSet("suspend")
+	toDisk := flags.Bool("to-disk", false, "Suspend the machine to disk (hibernate) instead of RAM")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return fmt.Errorf("suspend expects no arguments after flags, got %d", flags.NArg())
+	}
+
+	d, err := machineFlags.loadDriver()
+	if err != nil {
+		return err
+	}
+
+	return d.Suspend(ctx, *toDisk)
+}
+
+// Resumes a machine suspended to RAM or disk.
+func runResumeCommand(ctx context.Context, args []string) error {
+	flags, machineFlags := newMachineFlagSet("resume")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return fmt.Errorf("resume expects no arguments after flags, got %d", flags.NArg())
+	}
+
+	d, err := machineFlags.loadDriver()
+	if err != nil {
+		return err
+	}
+
+	return d.Resume(ctx)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Usage of the snapshot command, excluding flags.
+const snapshotUsage = "<create|list|rollback|delete> --machine-dir <path> [flags] [name]"
+
+// Manages snapshots of a machine.
+func runSnapshotCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s snapshot %s", os.Args[0], snapshotUsage)
+	}
+
+	action := args[0]
+	flags, machineFlags := newMachineFlagSet("snapshot " + action)
+
+	var (
+		withRAM     = new(bool)
+		description = new(string)
+		nameCount   = 1
+	)
+
+	switch action {
+	case "create":
+		withRAM = flags.Bool("ram", false, "Include RAM state of the running machine in the snapshot")
+		description = flags.String("description", "", "Description of the snapshot")
+	case "list":
+		nameCount = 0
+	case "rollback", "delete":
+	default:
+		return fmt.Errorf("unknown snapshot action '%s', use one of 'create', 'list', 'rollback' or 'delete'", action)
+	}
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if flags.NArg() != nameCount {
+		return fmt.Errorf("snapshot %s expects %d argument(s) after flags, got %d", action, nameCount, flags.NArg())
+	}
+
+	d, err := machineFlags.loadDriver()
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "create":
+		return d.CreateSnapshot(ctx, flags.Arg(0), *description, *withRAM)
+	case "rollback":
+		return d.RollbackSnapshot(ctx, flags.Arg(0))
+	case "delete":
+		return d.DeleteSnapshot(ctx, flags.Arg(0))
+	}
+
+	snapshots, err := d.ListSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint:mnd
+
+	fmt.Fprintln(writer, "NAME\tCREATED\tRAM\tPARENT\tDESCRIPTION")
+
+	for _, snapshot := range snapshots {
+		fmt.Fprintf(
+			writer,
+			"%s\t%s\t%s\t%s\t%s\n",
+			snapshot.Name,
+			time.Unix(snapshot.Snaptime, 0).Format(time.RFC3339),
+			strconv.FormatBool(snapshot.Vmstate == 1),
+			snapshot.Parent,
+			snapshot.Description,
+		)
+	}
+
+	return writer.Flush()
+}